@@ -0,0 +1,315 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// announceWithin is how recent a message must be for its badges to be
+// announced. Older ones come from backtracking and are only stored.
+const announceWithin = 10 * time.Minute
+
+// badgesJob is the name of the weekly job in the schedules table that awards
+// the weekly_top badge once a week is over. Every guild gets it when the bot
+// joins or reconnects.
+const (
+	badgesJob  = "badges"
+	badgesCron = "0 0 * * 1"
+)
+
+// weeklyTopBadge is only awarded by the badges job, as the weekly board is
+// not settled until the week is over.
+const weeklyTopBadge = "weekly_top"
+
+type achievement struct {
+	ID          string
+	Emoji       string
+	Name        string
+	Description string
+}
+
+// achievements lists every badge in the order /badges shows them. The IDs are
+// stored in the achievements table and must not change.
+var achievements = []achievement{
+	{"first_offense", "🐣", "First offense", "Got flagged for the first time"},
+	{"hundred", "💯", "Centurion", "100 flagged messages"},
+	{"vocabulary", "📚", "Rich vocabulary", "Used ten different flagged words"},
+	{"streak_7", "🔥", "On a roll", "Flagged seven days in a row"},
+	{weeklyTopBadge, "👑", "Dirtiest of the week", "Topped the weekly scoreboard"},
+}
+
+// userStats holds what the badges are decided on.
+type userStats struct {
+	Messages   int
+	Words      int
+	BestStreak int
+}
+
+// fetchEarned returns when the user earned each of their badges.
+func fetchEarned(ctx context.Context, guildID, userID string) (map[string]time.Time, error) {
+	rows, err := dbpool.Query(ctx, `SELECT achievement, earned_at FROM achievements WHERE serverid = $1 AND userid = $2;`, guildID, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	earned := map[string]time.Time{}
+	for rows.Next() {
+		var id string
+		var at time.Time
+		if err := rows.Scan(&id, &at); err != nil {
+			return nil, err
+		}
+		earned[id] = at
+	}
+	return earned, rows.Err()
+}
+
+// fetchUserStats gathers the numbers the badges need. The streak is only
+// looked up while the badge that needs it is still missing.
+func fetchUserStats(ctx context.Context, guildID, userID string, earned map[string]time.Time) (userStats, error) {
+	var stats userStats
+	query := `SELECT (SELECT COUNT(*) FROM messages WHERE serverID = $1 AND userID = $2),
+		(SELECT COUNT(DISTINCT u.wordID) FROM messages CROSS JOIN LATERAL UNNEST(wordID) AS u(wordID) WHERE serverID = $1 AND userID = $2);`
+	if err := dbpool.QueryRow(ctx, query, guildID, userID).Scan(&stats.Messages, &stats.Words); err != nil {
+		return stats, fmt.Errorf("counting messages: %v", err)
+	}
+
+	if _, ok := earned["streak_7"]; !ok {
+		err := dbpool.QueryRow(ctx, `SELECT COALESCE(MAX(best), 0) FROM streaks WHERE serverid = $1 AND userid = $2;`, guildID, userID).Scan(&stats.BestStreak)
+		if err != nil {
+			return stats, fmt.Errorf("fetching streak: %v", err)
+		}
+	}
+	return stats, nil
+}
+
+// qualifies tells whether stats are enough for the badge.
+func (a achievement) qualifies(stats userStats) bool {
+	switch a.ID {
+	case "first_offense":
+		return stats.Messages >= 1
+	case "hundred":
+		return stats.Messages >= 100
+	case "vocabulary":
+		return stats.Words >= 10
+	case "streak_7":
+		return stats.BestStreak >= 7
+	}
+	return false
+}
+
+// checkAchievements awards the badges the author of a just stored message
+// has earned, announcing them when the guild has an announcement channel and
+// the message is new. Backtracking leaves this to recomputeAchievements.
+func checkAchievements(s *discordgo.Session, msg *discordgo.Message, guildID string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	userID := msg.Author.ID
+	earned, err := fetchEarned(ctx, guildID, userID)
+	if err != nil {
+		log.Printf("Error fetching achievements: %v", err)
+		return
+	}
+	missing := false
+	for _, a := range achievements {
+		if _, ok := earned[a.ID]; !ok && a.ID != weeklyTopBadge {
+			missing = true
+		}
+	}
+	if !missing {
+		return
+	}
+
+	stats, err := fetchUserStats(ctx, guildID, userID, earned)
+	if err != nil {
+		log.Printf("Error fetching achievement stats: %v", err)
+		return
+	}
+
+	var unlocked []achievement
+	for _, a := range achievements {
+		if _, ok := earned[a.ID]; ok || !a.qualifies(stats) {
+			continue
+		}
+		tag, err := dbpool.Exec(ctx, `INSERT INTO achievements (serverid, userid, achievement, earned_at) VALUES ($1, $2, $3, $4)
+			ON CONFLICT DO NOTHING;`, guildID, userID, a.ID, msg.Timestamp)
+		if err != nil {
+			log.Printf("Error storing achievement: %v", err)
+			continue
+		}
+		if tag.RowsAffected() > 0 {
+			unlocked = append(unlocked, a)
+		}
+	}
+
+	if len(unlocked) == 0 || time.Since(msg.Timestamp) > announceWithin {
+		return
+	}
+	if err := announceAchievements(s, guildID, userID, unlocked); err != nil {
+		log.Printf("Error announcing achievements: %v", err)
+	}
+}
+
+// announceAchievements tells the guild's announcement channel, if it has
+// one, about badges a user unlocked.
+func announceAchievements(s *discordgo.Session, guildID, userID string, unlocked []achievement) error {
+	channelID := getGuildSettings(guildID).Announcements
+	if channelID == "" {
+		return nil
+	}
+	var lines []string
+	for _, a := range unlocked {
+		lines = append(lines, fmt.Sprintf("%s **%s**: %s", a.Emoji, a.Name, a.Description))
+	}
+	_, err := s.ChannelMessageSendComplex(channelID, &discordgo.MessageSend{
+		Content:         fmt.Sprintf("<@%s> unlocked:\n%s", userID, strings.Join(lines, "\n")),
+		AllowedMentions: &discordgo.MessageAllowedMentions{},
+	})
+	return err
+}
+
+// awardWeeklyTops awards weekly_top to the #1 of every week, in the guild's
+// time zone, that ended by before, which must be the start of a week. Ties
+// all get it. It returns who got the badge now and when their week ended.
+func awardWeeklyTops(ctx context.Context, guildID string, before time.Time) (map[string]time.Time, error) {
+	query := `WITH weekly AS (
+			SELECT userID, date_trunc('week', timestamp AT TIME ZONE $2) AS week, COUNT(*) AS message_count
+			FROM messages WHERE serverID = $1 AND timestamp < $3 GROUP BY userID, week),
+		ranked AS (SELECT userID, week, RANK() OVER (PARTITION BY week ORDER BY message_count DESC) AS position FROM weekly)
+		INSERT INTO achievements (serverid, userid, achievement, earned_at)
+		SELECT $1, userID, $4, MIN(week + interval '7 days') AT TIME ZONE $2 FROM ranked WHERE position = 1 GROUP BY userID
+		ON CONFLICT DO NOTHING RETURNING userid, earned_at;`
+	rows, err := dbpool.Query(ctx, query, guildID, getGuildSettings(guildID).location().String(), before, weeklyTopBadge)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	awarded := map[string]time.Time{}
+	for rows.Next() {
+		var userID string
+		var at time.Time
+		if err := rows.Scan(&userID, &at); err != nil {
+			return nil, err
+		}
+		awarded[userID] = at
+	}
+	return awarded, rows.Err()
+}
+
+// postWeeklyBadges is the scheduled job awarding weekly_top once a week is
+// over. Weeks missed while the bot was down are caught up, but only the week
+// that just ended is announced.
+func postWeeklyBadges(s *discordgo.Session, sched schedule, at time.Time) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	week := periodStart(periodWeek, at.In(getGuildSettings(sched.ServerID).location()))
+	awarded, err := awardWeeklyTops(ctx, sched.ServerID, week)
+	if err != nil {
+		return err
+	}
+
+	var badge []achievement
+	for _, a := range achievements {
+		if a.ID == weeklyTopBadge {
+			badge = append(badge, a)
+		}
+	}
+	for userID, earnedAt := range awarded {
+		if !earnedAt.Equal(week) {
+			continue
+		}
+		if err := announceAchievements(s, sched.ServerID, userID, badge); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// recomputeAchievements awards every badge a guild's stored messages and
+// streaks have earned, dated when they were earned where the messages tell.
+// It is one pass over the guild instead of one per message, for after
+// backtracking and after the streaks are rebuilt. Nothing is announced.
+func recomputeAchievements(ctx context.Context, guildID string, now time.Time) error {
+	queries := []string{
+		`INSERT INTO achievements (serverid, userid, achievement, earned_at)
+			SELECT $1, userID, 'first_offense', MIN(timestamp) FROM messages WHERE serverID = $1 GROUP BY userID
+			ON CONFLICT DO NOTHING;`,
+		`INSERT INTO achievements (serverid, userid, achievement, earned_at)
+			SELECT $1, userID, 'hundred', timestamp FROM (
+				SELECT userID, timestamp, ROW_NUMBER() OVER (PARTITION BY userID ORDER BY timestamp) AS n
+				FROM messages WHERE serverID = $1) numbered WHERE n = 100
+			ON CONFLICT DO NOTHING;`,
+		`INSERT INTO achievements (serverid, userid, achievement, earned_at)
+			SELECT $1, userID, 'vocabulary', first_use FROM (
+				SELECT userID, first_use, ROW_NUMBER() OVER (PARTITION BY userID ORDER BY first_use) AS n FROM (
+					SELECT userID, MIN(timestamp) AS first_use FROM messages CROSS JOIN LATERAL UNNEST(wordID) AS u(wordID)
+					WHERE serverID = $1 GROUP BY userID, u.wordID) used) numbered WHERE n = 10
+			ON CONFLICT DO NOTHING;`,
+		`INSERT INTO achievements (serverid, userid, achievement)
+			SELECT serverid, userid, 'streak_7' FROM streaks WHERE serverid = $1 AND best >= 7
+			ON CONFLICT DO NOTHING;`,
+	}
+	for _, query := range queries {
+		if _, err := dbpool.Exec(ctx, query, guildID); err != nil {
+			return err
+		}
+	}
+
+	_, err := awardWeeklyTops(ctx, guildID, periodStart(periodWeek, now.In(getGuildSettings(guildID).location())))
+	return err
+}
+
+func badgesHandler(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	if err := acknowledgeInteraction(s, i); err != nil {
+		return
+	}
+
+	var user *discordgo.User
+	if option, ok := optionsByName(i.ApplicationCommandData().Options)["user"]; ok {
+		user = option.UserValue(s)
+	} else if i.Member != nil {
+		user = i.Member.User
+	} else {
+		user = i.User
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	earned, err := fetchEarned(ctx, i.GuildID, user.ID)
+	if err != nil {
+		log.Printf("Error fetching achievements: %v", err)
+		if err := sendResponse(s, i, "Failed to fetch badges"); err != nil {
+			log.Printf("Error sending detailed response: %v", err)
+		}
+		return
+	}
+
+	var lines []string
+	for _, a := range achievements {
+		if at, ok := earned[a.ID]; ok {
+			lines = append(lines, fmt.Sprintf("%s **%s**: %s, %s", a.Emoji, a.Name, a.Description, discordTimestamp(at, timestampLongDate)))
+		} else {
+			lines = append(lines, fmt.Sprintf("🔒 ~~%s~~: %s", a.Name, a.Description))
+		}
+	}
+
+	embed := &discordgo.MessageEmbed{
+		Title:       fmt.Sprintf("Badges of %s", user.Username),
+		Description: strings.Join(lines, "\n"),
+		Color:       0xff0000,
+		Footer:      &discordgo.MessageEmbedFooter{Text: fmt.Sprintf("%d of %d unlocked", len(earned), len(achievements))},
+	}
+	if err := sendEmbedResponse(s, i, embed); err != nil {
+		log.Printf("Error sending detailed response: %v", err)
+	}
+}
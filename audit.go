@@ -0,0 +1,19 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// recordAudit writes an admin action to the audit_log table. Failing to
+// record is logged but never stops the action itself.
+func recordAudit(guildID, userID, action, details string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	query := `INSERT INTO audit_log (serverid, userid, action, details) VALUES ($1, $2, $3, $4);`
+	if _, err := dbpool.Exec(ctx, query, guildID, userID, action, details); err != nil {
+		log.Printf("Error recording audit entry %s: %v", action, err)
+	}
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// maxAutocompleteChoices is the most choices Discord accepts in one response.
+const maxAutocompleteChoices = 25
+
+// autocompleteHandlers serve autocomplete interactions by command name. Every
+// option taking an existing flagged word should have Autocomplete set and its
+// command listed here.
+var autocompleteHandlers = map[string]func(s *discordgo.Session, i *discordgo.InteractionCreate){
+	"unholyremove": wordAutocomplete,
+	"unholy":       filterAutocomplete,
+	"random":       filterAutocomplete,
+	"chart":        filterAutocomplete,
+	"export":       filterAutocomplete,
+}
+
+// focusedOption finds the option the user is typing in, looking inside
+// subcommands and subcommand groups.
+func focusedOption(options []*discordgo.ApplicationCommandInteractionDataOption) *discordgo.ApplicationCommandInteractionDataOption {
+	for _, option := range options {
+		if option.Focused {
+			return option
+		}
+		if found := focusedOption(option.Options); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+// wordAutocomplete suggests flagged words from the in-memory matcher, words
+// starting with the typed text first and then words containing it.
+func wordAutocomplete(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	option := focusedOption(i.ApplicationCommandData().Options)
+	if option == nil {
+		return
+	}
+	typed := strings.ToLower(option.StringValue())
+
+	var prefixed, contained []string
+	if matcher := currentMatcher.Load(); matcher != nil {
+		for _, w := range matcher.words {
+			switch {
+			case strings.HasPrefix(w.Word, typed):
+				prefixed = append(prefixed, w.Word)
+			case strings.Contains(w.Word, typed):
+				contained = append(contained, w.Word)
+			}
+		}
+	}
+	sort.Strings(prefixed)
+	sort.Strings(contained)
+
+	if err := respondAutocomplete(s, i, append(prefixed, contained...)); err != nil {
+		log.Printf("Error responding to autocomplete: %v", err)
+	}
+}
+
+// categoryAutocomplete suggests the categories used by the flagged words.
+func categoryAutocomplete(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	option := focusedOption(i.ApplicationCommandData().Options)
+	if option == nil {
+		return
+	}
+	typed := strings.ToLower(option.StringValue())
+
+	seen := make(map[string]bool)
+	var categories []string
+	if matcher := currentMatcher.Load(); matcher != nil {
+		for _, w := range matcher.words {
+			if w.Category == "" || seen[w.Category] || !strings.Contains(strings.ToLower(w.Category), typed) {
+				continue
+			}
+			seen[w.Category] = true
+			categories = append(categories, w.Category)
+		}
+	}
+	sort.Strings(categories)
+
+	if err := respondAutocomplete(s, i, categories); err != nil {
+		log.Printf("Error responding to autocomplete: %v", err)
+	}
+}
+
+// filterAutocomplete serves commands taking the options from filterOptions.
+func filterAutocomplete(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	option := focusedOption(i.ApplicationCommandData().Options)
+	if option == nil {
+		return
+	}
+	switch option.Name {
+	case "word":
+		wordAutocomplete(s, i)
+	case "category":
+		categoryAutocomplete(s, i)
+	}
+}
+
+func respondAutocomplete(s *discordgo.Session, i *discordgo.InteractionCreate, values []string) error {
+	choices := make([]*discordgo.ApplicationCommandOptionChoice, 0, maxAutocompleteChoices)
+	for _, value := range values {
+		if len(choices) == maxAutocompleteChoices {
+			break
+		}
+		// Discord rejects choice names and values over 100 characters.
+		if len(value) > 100 {
+			continue
+		}
+		choices = append(choices, &discordgo.ApplicationCommandOptionChoice{Name: value, Value: value})
+	}
+
+	return s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionApplicationCommandAutocompleteResult,
+		Data: &discordgo.InteractionResponseData{
+			Choices: choices,
+		},
+	})
+}
@@ -0,0 +1,302 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+const (
+	chartWidth  = 960
+	chartHeight = 480
+	chartScale  = 2
+)
+
+// Intervals a chart can group messages by. They double as date_trunc fields.
+const (
+	intervalDay   = "day"
+	intervalWeek  = "week"
+	intervalMonth = "month"
+)
+
+type chartSeries struct {
+	Name   string
+	Values []int
+}
+
+// niceCeiling rounds max up to a round number so the y axis gets round tick
+// labels.
+func niceCeiling(max int) int {
+	if max <= 5 {
+		return 5
+	}
+	magnitude := 1
+	for magnitude*10 < max {
+		magnitude *= 10
+	}
+	for _, tenths := range []int{10, 15, 20, 25, 30, 40, 50, 60, 80} {
+		if tenths*magnitude >= max*10 && tenths*magnitude%50 == 0 {
+			return tenths * magnitude / 10
+		}
+	}
+	return 10 * magnitude
+}
+
+// renderChart draws the series as a line or grouped bar chart over labels.
+func renderChart(title string, labels []string, series []chartSeries, bars bool) *image.RGBA {
+	img := newCanvas(chartWidth, chartHeight)
+	line := textHeight(chartScale)
+
+	drawText(img, 20, 16, title, chartScale, foregroundColor)
+
+	// The legend sits under the title, one entry per series.
+	legendX := 20
+	for k, s := range series {
+		c := paletteColors[k%len(paletteColors)]
+		fillRect(img, image.Rect(legendX, 24+line, legendX+line, 24+2*line), c)
+		drawText(img, legendX+line+6, 24+line, s.Name, chartScale, mutedColor)
+		legendX += line + 6 + textWidth(s.Name, chartScale) + 24
+	}
+
+	max := 0
+	for _, s := range series {
+		for _, v := range s.Values {
+			if v > max {
+				max = v
+			}
+		}
+	}
+	yMax := niceCeiling(max)
+
+	plot := image.Rect(20+textWidth(fmt.Sprint(yMax), chartScale)+10, 40+2*line, chartWidth-20, chartHeight-20-line-8)
+	yFor := func(v int) int {
+		return plot.Max.Y - v*plot.Dy()/yMax
+	}
+
+	const ticks = 5
+	for t := 0; t <= ticks; t++ {
+		v := yMax * t / ticks
+		y := yFor(v)
+		fillRect(img, image.Rect(plot.Min.X, y, plot.Max.X, y+1), gridColor)
+		label := fmt.Sprint(v)
+		drawText(img, plot.Min.X-10-textWidth(label, chartScale), y-line/2, label, chartScale, mutedColor)
+	}
+
+	n := len(labels)
+	if n == 0 {
+		return img
+	}
+	slot := float64(plot.Dx()) / float64(n)
+	xFor := func(k int) int {
+		return plot.Min.X + int(slot*(float64(k)+0.5))
+	}
+
+	// Only label as many buckets as fit without overlapping.
+	widest := 0
+	for _, label := range labels {
+		if w := textWidth(label, chartScale); w > widest {
+			widest = w
+		}
+	}
+	every := int(float64(widest+16)/slot) + 1
+	for k := 0; k < n; k += every {
+		x := xFor(k) - textWidth(labels[k], chartScale)/2
+		drawText(img, x, plot.Max.Y+8, labels[k], chartScale, mutedColor)
+	}
+
+	for k, s := range series {
+		c := paletteColors[k%len(paletteColors)]
+		if bars {
+			width := int(slot*0.8) / len(series)
+			if width < 1 {
+				width = 1
+			}
+			for b, v := range s.Values {
+				x := xFor(b) - width*len(series)/2 + k*width
+				fillRect(img, image.Rect(x, yFor(v), x+width, plot.Max.Y), c)
+			}
+			continue
+		}
+		for b := 1; b < len(s.Values); b++ {
+			drawLine(img, xFor(b-1), yFor(s.Values[b-1]), xFor(b), yFor(s.Values[b]), 3, c)
+		}
+		if len(s.Values) == 1 {
+			fillRect(img, image.Rect(xFor(0)-3, yFor(s.Values[0])-3, xFor(0)+3, yFor(s.Values[0])+3), c)
+		}
+	}
+	return img
+}
+
+// bucketStart aligns t to the start of its day, week (Monday) or month.
+func bucketStart(interval string, t time.Time) time.Time {
+	switch interval {
+	case intervalWeek:
+		return periodStart(periodWeek, t)
+	case intervalMonth:
+		return periodStart(periodMonth, t)
+	}
+	return periodStart(periodToday, t)
+}
+
+func nextBucket(interval string, t time.Time) time.Time {
+	switch interval {
+	case intervalWeek:
+		return t.AddDate(0, 0, 7)
+	case intervalMonth:
+		return t.AddDate(0, 1, 0)
+	}
+	return t.AddDate(0, 0, 1)
+}
+
+func bucketLabel(interval string, t time.Time) string {
+	if interval == intervalMonth {
+		return t.Format("2006-01")
+	}
+	return t.Format("01-02")
+}
+
+// fetchActivity counts messages per bucket in [from, to), one series per user
+// or a single series for everyone when users is empty. Buckets are days,
+// weeks or months in loc.
+func fetchActivity(ctx context.Context, filter messageFilter, interval string, users []*discordgo.User, loc *time.Location) ([]string, []chartSeries, error) {
+	where, args := filter.where("", nil)
+	args = append(args, interval, loc.String())
+	query := fmt.Sprintf(`SELECT date_trunc($%d, timestamp AT TIME ZONE $%d) AS bucket, userID, COUNT(*) FROM messages %s GROUP BY bucket, userID;`, len(args)-1, len(args), where)
+	rows, err := dbpool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	// Buckets come back as local times without a zone, so they are matched
+	// by their date.
+	var labels []string
+	index := make(map[string]int)
+	for t := bucketStart(interval, filter.From.In(loc)); t.Before(filter.To); t = nextBucket(interval, t) {
+		index[t.Format(dateLayout)] = len(labels)
+		labels = append(labels, bucketLabel(interval, t))
+	}
+
+	series := []chartSeries{{Name: "Everyone", Values: make([]int, len(labels))}}
+	seriesOf := map[string]int{}
+	if len(users) > 0 {
+		series = series[:0]
+		for k, user := range users {
+			series = append(series, chartSeries{Name: user.Username, Values: make([]int, len(labels))})
+			seriesOf[user.ID] = k
+		}
+	}
+
+	for rows.Next() {
+		var bucket time.Time
+		var userID string
+		var count int
+		if err := rows.Scan(&bucket, &userID, &count); err != nil {
+			return nil, nil, err
+		}
+		b, ok := index[bucket.Format(dateLayout)]
+		if !ok {
+			continue
+		}
+		if len(users) == 0 {
+			series[0].Values[b] += count
+		} else if k, ok := seriesOf[userID]; ok {
+			series[k].Values[b] += count
+		}
+	}
+	return labels, series, rows.Err()
+}
+
+func chartHandler(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	if err := acknowledgeInteraction(s, i); err != nil {
+		return
+	}
+
+	options := optionsByName(i.ApplicationCommandData().Options)
+	interval := intervalDay
+	if option, ok := options["interval"]; ok {
+		interval = option.StringValue()
+	}
+	bars := false
+	if option, ok := options["style"]; ok {
+		bars = option.StringValue() == "bar"
+	}
+
+	var users []*discordgo.User
+	for _, name := range []string{"user1", "user2", "user3"} {
+		if option, ok := options[name]; ok {
+			users = append(users, option.UserValue(s))
+		}
+	}
+
+	loc := getGuildSettings(i.GuildID).location()
+	filter := scopeFilter(i)
+	if err := filter.applyFilterOptions(options, loc); err != nil {
+		if err := sendResponse(s, i, err.Error()); err != nil {
+			log.Printf("Error sending detailed response: %v", err)
+		}
+		return
+	}
+	now := time.Now().In(loc)
+	if filter.To.IsZero() {
+		filter.To = nextBucket(interval, bucketStart(interval, now))
+	}
+	if filter.From.IsZero() {
+		switch interval {
+		case intervalDay:
+			filter.From = filter.To.AddDate(0, 0, -30)
+		case intervalWeek:
+			filter.From = filter.To.AddDate(0, 0, -7*12)
+		case intervalMonth:
+			filter.From = filter.To.AddDate(0, -12, 0)
+		}
+	}
+	if filter.To.Sub(filter.From) > 5*366*24*time.Hour {
+		if err := sendResponse(s, i, "That range is too long to chart, pick at most five years."); err != nil {
+			log.Printf("Error sending detailed response: %v", err)
+		}
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	labels, series, err := fetchActivity(ctx, filter, interval, users, loc)
+	if err != nil {
+		log.Printf("Error executing chart query: %v", err)
+		if err := sendResponse(s, i, "Failed to fetch activity"); err != nil {
+			log.Printf("Error sending detailed response: %v", err)
+		}
+		return
+	}
+
+	title := fmt.Sprintf("Flagged messages per %s", interval)
+	buf, err := encodePNG(renderChart(title, labels, series, bars))
+	if err != nil {
+		log.Printf("Error rendering chart: %v", err)
+		if err := sendResponse(s, i, "Failed to render chart"); err != nil {
+			log.Printf("Error sending detailed response: %v", err)
+		}
+		return
+	}
+
+	var names []string
+	for _, s := range series {
+		names = append(names, s.Name)
+	}
+	response := fmt.Sprintf("Flagged messages per %s for %s, %s to %s", interval, strings.Join(names, ", "),
+		discordTimestamp(filter.From, timestampLongDate), discordTimestamp(filter.To.Add(-time.Second), timestampLongDate))
+	file := &discordgo.File{
+		Name:        "chart.png",
+		ContentType: "image/png",
+		Reader:      buf,
+	}
+	if err := sendFileResponse(s, i, response, file); err != nil {
+		log.Printf("Error sending detailed response: %v", err)
+	}
+}
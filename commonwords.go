@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+type wordUsage struct {
+	Word        string
+	UserCount   int
+	ServerCount int
+}
+
+// signatureWord picks the word user over-uses the most compared with everyone
+// else: the highest ratio between the user's rate of using it and the rate of
+// all other users. Add-one smoothing keeps words nobody else uses from
+// dividing by zero while still ranking them highly.
+func signatureWord(usages []wordUsage) (wordUsage, float64, bool) {
+	userTotal, serverTotal := 0, 0
+	for _, usage := range usages {
+		userTotal += usage.UserCount
+		serverTotal += usage.ServerCount
+	}
+	othersTotal := serverTotal - userTotal
+	if userTotal == 0 {
+		return wordUsage{}, 0, false
+	}
+
+	var best wordUsage
+	bestLift := 0.0
+	for _, usage := range usages {
+		if usage.UserCount == 0 {
+			continue
+		}
+		userRate := float64(usage.UserCount) / float64(userTotal)
+		othersRate := float64(usage.ServerCount-usage.UserCount+1) / float64(othersTotal+len(usages))
+		if lift := userRate / othersRate; lift > bestLift {
+			best, bestLift = usage, lift
+		}
+	}
+	return best, bestLift, bestLift > 0
+}
+
+// commonWordsForUser answers /commonwords with a user: that user's word
+// counts, their share of each word across the server and their signature word.
+func commonWordsForUser(s *discordgo.Session, i *discordgo.InteractionCreate, user *discordgo.User, filter messageFilter) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	where, args := filter.where("", nil)
+	args = append(args, user.ID)
+	query := fmt.Sprintf(`SELECT w.word, COUNT(*) FILTER (WHERE m.userID = $%d) AS user_count, COUNT(*) AS server_count
+		FROM words w JOIN (SELECT userID, UNNEST(wordID) AS wordID FROM messages %s) m ON w.wordID = m.wordID
+		GROUP BY w.word ORDER BY user_count DESC, server_count DESC;`, len(args), where)
+
+	rows, err := dbpool.Query(ctx, query, args...)
+	if err != nil {
+		log.Printf("Error executing commonwords query: %v", err)
+		if err := sendResponse(s, i, "Failed to fetch common words"); err != nil {
+			log.Printf("Error sending detailed response: %v", err)
+		}
+		return
+	}
+	defer rows.Close()
+
+	var usages []wordUsage
+	for rows.Next() {
+		var usage wordUsage
+		if err := rows.Scan(&usage.Word, &usage.UserCount, &usage.ServerCount); err != nil {
+			log.Printf("Error scanning row: %v", err)
+			continue
+		}
+		usages = append(usages, usage)
+	}
+
+	if err := rows.Err(); err != nil {
+		log.Printf("Error processing common words results: %v", err)
+		if err := sendResponse(s, i, "Error processing common word response"); err != nil {
+			log.Printf("Error sending detailed response: %v", err)
+		}
+		return
+	}
+
+	var results []string
+	for _, usage := range usages {
+		if usage.UserCount == 0 {
+			break
+		}
+		share := 100 * float64(usage.UserCount) / float64(usage.ServerCount)
+		results = append(results, fmt.Sprintf("%s: %d (%.0f%% of all %d uses)", usage.Word, usage.UserCount, share, usage.ServerCount))
+	}
+
+	if len(results) == 0 {
+		response := fmt.Sprintf("%v is a boring bitch. Gaslight them to join the list ;D", user.Username)
+		if err := sendResponse(s, i, response); err != nil {
+			log.Printf("Error sending detailed response: %v", err)
+		}
+		return
+	}
+
+	response := fmt.Sprintf("Common Words Usage of %s:\n", user.Username) + strings.Join(results, "\n")
+	if signature, lift, ok := signatureWord(usages); ok {
+		response += fmt.Sprintf("\n\nSignature word: **%s**, used %.1f× as often as everyone else", signature.Word, lift)
+	}
+	if err := sendResponse(s, i, response); err != nil {
+		log.Printf("Error sending detailed response: %v", err)
+	}
+}
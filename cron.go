@@ -0,0 +1,155 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cronSpec is a parsed five field cron expression: minute, hour, day of
+// month, month and day of week. Each field is a bitset of the values it
+// allows. Like classic cron, when both day fields are restricted a day
+// matching either of them is enough.
+type cronSpec struct {
+	minute, hour, dom, month, dow uint64
+	domAny, dowAny                bool
+}
+
+type cronField struct {
+	min, max int
+}
+
+var cronFields = [5]cronField{
+	{0, 59}, // minute
+	{0, 23}, // hour
+	{1, 31}, // day of month
+	{1, 12}, // month
+	{0, 7},  // day of week, 0 and 7 are both Sunday
+}
+
+// parseCron parses expressions such as "0 18 * * 5" or "*/15 8-16 * * 1-5".
+// Fields take *, single values, ranges, steps and comma separated lists.
+func parseCron(expr string) (cronSpec, error) {
+	parts := strings.Fields(expr)
+	if len(parts) != 5 {
+		return cronSpec{}, fmt.Errorf("cron expression %q must have 5 fields, has %d", expr, len(parts))
+	}
+
+	var sets [5]uint64
+	for k, part := range parts {
+		set, err := parseCronField(part, cronFields[k])
+		if err != nil {
+			return cronSpec{}, fmt.Errorf("cron expression %q: %v", expr, err)
+		}
+		sets[k] = set
+	}
+
+	spec := cronSpec{
+		minute: sets[0],
+		hour:   sets[1],
+		dom:    sets[2],
+		month:  sets[3],
+		dow:    sets[4],
+		domAny: parts[2] == "*",
+		dowAny: parts[4] == "*",
+	}
+	if spec.dow&(1<<7) != 0 {
+		spec.dow |= 1
+	}
+	return spec, nil
+}
+
+func parseCronField(field string, bounds cronField) (uint64, error) {
+	var set uint64
+	for _, item := range strings.Split(field, ",") {
+		rangePart, step := item, 1
+		if slash := strings.Index(item, "/"); slash >= 0 {
+			n, err := strconv.Atoi(item[slash+1:])
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("bad step in %q", item)
+			}
+			rangePart, step = item[:slash], n
+		}
+
+		from, to := bounds.min, bounds.max
+		if rangePart != "*" {
+			ends := strings.SplitN(rangePart, "-", 2)
+			var err error
+			if from, err = strconv.Atoi(ends[0]); err != nil {
+				return 0, fmt.Errorf("bad value %q", ends[0])
+			}
+			to = from
+			if len(ends) == 2 {
+				if to, err = strconv.Atoi(ends[1]); err != nil {
+					return 0, fmt.Errorf("bad value %q", ends[1])
+				}
+			} else if step > 1 {
+				to = bounds.max
+			}
+		}
+		if from < bounds.min || to > bounds.max || from > to {
+			return 0, fmt.Errorf("%q is outside %d-%d", item, bounds.min, bounds.max)
+		}
+
+		for v := from; v <= to; v += step {
+			set |= 1 << v
+		}
+	}
+	return set, nil
+}
+
+func (c cronSpec) dayMatches(t time.Time) bool {
+	domOK := c.dom&(1<<t.Day()) != 0
+	dowOK := c.dow&(1<<t.Weekday()) != 0
+	switch {
+	case c.domAny && c.dowAny:
+		return true
+	case c.domAny:
+		return dowOK
+	case c.dowAny:
+		return domOK
+	}
+	return domOK || dowOK
+}
+
+// allHours is the hour set of "*".
+const allHours = 1<<24 - 1
+
+// next returns the first time after t that matches, in t's location. Times
+// that do not exist because of a daylight saving switch are skipped that day.
+// Times that happen twice when the clocks go back match only the first time,
+// unless the hour field is "*", as in classic cron. The zero time is returned
+// if nothing matches within five years, as for "0 0 30 2 *".
+func (c cronSpec) next(t time.Time) time.Time {
+	loc := t.Location()
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+
+	for t.Before(limit) {
+		switch {
+		case c.month&(1<<int(t.Month())) == 0:
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, loc)
+		case !c.dayMatches(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc)
+		case c.hour&(1<<t.Hour()) == 0:
+			// Counting whole minutes rather than calling time.Date keeps to
+			// the first of two hours with the same clock time.
+			t = t.Add(time.Duration(60-t.Minute()) * time.Minute)
+		case c.minute&(1<<t.Minute()) == 0:
+			t = t.Add(time.Minute)
+		case c.hour != allHours && repeatedClock(t):
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// repeatedClock reports whether the clock showed the same time an hour before
+// t, which happens in the hour repeated when the clocks go back.
+func repeatedClock(t time.Time) bool {
+	before := t.Add(-time.Hour)
+	return before.Hour() == t.Hour() && before.Minute() == t.Minute() && before.Day() == t.Day()
+}
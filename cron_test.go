@@ -0,0 +1,187 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func mustLocation(t *testing.T, name string) *time.Location {
+	t.Helper()
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		t.Fatalf("loading %s: %v", name, err)
+	}
+	return loc
+}
+
+func TestParseCronInvalid(t *testing.T) {
+	for _, expr := range []string{
+		"",
+		"* * * *",
+		"* * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * 32 * *",
+		"* * * 0 *",
+		"* * * 13 *",
+		"* * * * 8",
+		"-1 * * * *",
+		"5-1 * * * *",
+		"*/0 * * * *",
+		"*/x * * * *",
+		"1-2-3 * * * *",
+		"a * * * *",
+		"1,,2 * * * *",
+		"1- * * * *",
+	} {
+		if _, err := parseCron(expr); err == nil {
+			t.Errorf("parseCron(%q) succeeded, want an error", expr)
+		}
+	}
+}
+
+func TestParseCronFields(t *testing.T) {
+	bits := func(values ...int) uint64 {
+		var set uint64
+		for _, v := range values {
+			set |= 1 << v
+		}
+		return set
+	}
+
+	tests := []struct {
+		expr   string
+		minute uint64
+		dow    uint64
+	}{
+		{"0 * * * *", bits(0), bits(0, 1, 2, 3, 4, 5, 6, 7)},
+		{"*/20 * * * 1-5", bits(0, 20, 40), bits(1, 2, 3, 4, 5)},
+		{"5/20 * * * 1,3,5", bits(5, 25, 45), bits(1, 3, 5)},
+		{"10-30/10 * * * 7", bits(10, 20, 30), bits(0, 7)},
+		{"1,2,58-59 * * * 5-7", bits(1, 2, 58, 59), bits(0, 5, 6, 7)},
+	}
+	for _, tt := range tests {
+		spec, err := parseCron(tt.expr)
+		if err != nil {
+			t.Errorf("parseCron(%q): %v", tt.expr, err)
+			continue
+		}
+		if spec.minute != tt.minute {
+			t.Errorf("parseCron(%q) minutes = %b, want %b", tt.expr, spec.minute, tt.minute)
+		}
+		if spec.dow != tt.dow {
+			t.Errorf("parseCron(%q) weekdays = %b, want %b", tt.expr, spec.dow, tt.dow)
+		}
+	}
+}
+
+func TestCronNext(t *testing.T) {
+	utc := func(year int, month time.Month, day, hour, minute int) time.Time {
+		return time.Date(year, month, day, hour, minute, 0, 0, time.UTC)
+	}
+
+	tests := []struct {
+		name string
+		expr string
+		from time.Time
+		want time.Time
+	}{
+		{"every minute", "* * * * *", utc(2025, 1, 1, 10, 0), utc(2025, 1, 1, 10, 1)},
+		{"strictly after", "0 10 * * *", utc(2025, 1, 1, 10, 0), utc(2025, 1, 2, 10, 0)},
+		{"seconds are dropped", "1 10 * * *", utc(2025, 1, 1, 10, 0).Add(59 * time.Second), utc(2025, 1, 1, 10, 1)},
+		{"list", "5,35 * * * *", utc(2025, 1, 1, 10, 5), utc(2025, 1, 1, 10, 35)},
+		{"step", "*/15 * * * *", utc(2025, 1, 1, 10, 46), utc(2025, 1, 1, 11, 0)},
+		{"step from a value", "5/20 * * * *", utc(2025, 1, 1, 10, 26), utc(2025, 1, 1, 10, 45)},
+		{"stepped range", "10-30/10 * * * *", utc(2025, 1, 1, 10, 11), utc(2025, 1, 1, 10, 20)},
+		{"ranges over a weekend", "*/15 8-16 * * 1-5", utc(2025, 1, 3, 16, 50), utc(2025, 1, 6, 8, 0)},
+		{"month", "0 0 1 6 *", utc(2025, 7, 1, 0, 0), utc(2026, 6, 1, 0, 0)},
+		{"end of year", "0 0 * * *", utc(2025, 12, 31, 23, 59), utc(2026, 1, 1, 0, 0)},
+		{"7 is Sunday", "0 12 * * 7", utc(2025, 1, 1, 0, 0), utc(2025, 1, 5, 12, 0)},
+		{"0 is Sunday", "0 12 * * 0", utc(2025, 1, 1, 0, 0), utc(2025, 1, 5, 12, 0)},
+		{"range ending at 7", "0 12 * * 6-7", utc(2025, 1, 4, 13, 0), utc(2025, 1, 5, 12, 0)},
+		{"day of week alone", "0 0 * * 5", utc(2025, 1, 4, 0, 0), utc(2025, 1, 10, 0, 0)},
+		{"day of month alone", "0 0 13 * *", utc(2025, 1, 4, 0, 0), utc(2025, 1, 13, 0, 0)},
+		{"either day, weekday first", "0 0 13 * 5", utc(2025, 1, 1, 0, 0), utc(2025, 1, 3, 0, 0)},
+		{"either day, weekday again", "0 0 13 * 5", utc(2025, 1, 3, 0, 0), utc(2025, 1, 10, 0, 0)},
+		{"either day, date first", "0 0 13 * 5", utc(2025, 1, 10, 0, 0), utc(2025, 1, 13, 0, 0)},
+		{"leap day within the limit", "0 0 29 2 *", utc(2025, 3, 1, 0, 0), utc(2028, 2, 29, 0, 0)},
+		{"never", "0 0 30 2 *", utc(2025, 1, 1, 0, 0), time.Time{}},
+		{"no such day in these months", "0 0 31 4,6,9,11 *", utc(2025, 1, 1, 0, 0), time.Time{}},
+	}
+	for _, tt := range tests {
+		spec, err := parseCron(tt.expr)
+		if err != nil {
+			t.Errorf("%s: parseCron(%q): %v", tt.name, tt.expr, err)
+			continue
+		}
+		if got := spec.next(tt.from); !got.Equal(tt.want) {
+			t.Errorf("%s: %q after %v = %v, want %v", tt.name, tt.expr, tt.from, got, tt.want)
+		}
+	}
+}
+
+func TestCronNextLimit(t *testing.T) {
+	spec, err := parseCron("0 0 29 2 *")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 2100 is no leap year, so after 2096 the next leap day is in 2104, more
+	// than five years on.
+	from := time.Date(2095, 3, 1, 0, 0, 0, 0, time.UTC)
+	if got, want := spec.next(from), time.Date(2096, 2, 29, 0, 0, 0, 0, time.UTC); !got.Equal(want) {
+		t.Errorf("next leap day after %v = %v, want %v", from, got, want)
+	}
+	from = time.Date(2096, 3, 1, 0, 0, 0, 0, time.UTC)
+	if got := spec.next(from); !got.IsZero() {
+		t.Errorf("next leap day after %v = %v, want the zero time past the limit", from, got)
+	}
+}
+
+func TestCronNextDST(t *testing.T) {
+	oslo := mustLocation(t, "Europe/Oslo")
+	cest := time.FixedZone("CEST", 2*60*60)
+	cet := time.FixedZone("CET", 60*60)
+
+	tests := []struct {
+		name string
+		expr string
+		from time.Time
+		want time.Time
+	}{
+		// On 30 March 2025 the clocks jump from 02:00 to 03:00.
+		{"skipped time is skipped that day", "30 2 * * *",
+			time.Date(2025, 3, 30, 0, 0, 0, 0, cet), time.Date(2025, 3, 31, 2, 30, 0, 0, cest)},
+		{"hour after the gap", "30 3 * * *",
+			time.Date(2025, 3, 30, 0, 0, 0, 0, cet), time.Date(2025, 3, 30, 3, 30, 0, 0, cest)},
+		{"hours keep going over the gap", "0 * * * *",
+			time.Date(2025, 3, 30, 1, 30, 0, 0, cet), time.Date(2025, 3, 30, 3, 0, 0, 0, cest)},
+
+		// On 26 October 2025 the clocks go back from 03:00 to 02:00.
+		{"repeated time matches the first time", "30 2 * * *",
+			time.Date(2025, 10, 26, 0, 0, 0, 0, cest), time.Date(2025, 10, 26, 2, 30, 0, 0, cest)},
+		{"repeated time does not match again", "30 2 * * *",
+			time.Date(2025, 10, 26, 2, 30, 0, 0, cest), time.Date(2025, 10, 27, 2, 30, 0, 0, cet)},
+		{"repeated time from inside the first hour", "30 2 * * *",
+			time.Date(2025, 10, 26, 2, 10, 0, 0, cest), time.Date(2025, 10, 26, 2, 30, 0, 0, cest)},
+		{"every hour runs in both hours", "30 * * * *",
+			time.Date(2025, 10, 26, 2, 30, 0, 0, cest), time.Date(2025, 10, 26, 2, 30, 0, 0, cet)},
+		{"hour after the overlap", "0 3 * * *",
+			time.Date(2025, 10, 26, 2, 30, 0, 0, cest), time.Date(2025, 10, 26, 3, 0, 0, 0, cet)},
+	}
+	for _, tt := range tests {
+		spec, err := parseCron(tt.expr)
+		if err != nil {
+			t.Errorf("%s: parseCron(%q): %v", tt.name, tt.expr, err)
+			continue
+		}
+		got := spec.next(tt.from.In(oslo))
+		if !got.Equal(tt.want) {
+			t.Errorf("%s: %q after %v = %v, want %v", tt.name, tt.expr, tt.from.In(oslo), got, tt.want.In(oslo))
+		}
+		if got.Location() != oslo {
+			t.Errorf("%s: result is in %v, want Europe/Oslo", tt.name, got.Location())
+		}
+	}
+}
@@ -0,0 +1,291 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/jackc/pgx/v4"
+)
+
+// digestJob is the name of the weekly digest in the schedules table.
+const digestJob = "digest"
+
+// digestTop is how many entries each digest section lists.
+const digestTop = 5
+
+var weekdayChoices = []*discordgo.ApplicationCommandOptionChoice{
+	{Name: "Monday", Value: 1},
+	{Name: "Tuesday", Value: 2},
+	{Name: "Wednesday", Value: 3},
+	{Name: "Thursday", Value: 4},
+	{Name: "Friday", Value: 5},
+	{Name: "Saturday", Value: 6},
+	{Name: "Sunday", Value: 0},
+}
+
+type mover struct {
+	UserID string
+	Delta  int
+}
+
+// fetchNewEntrants finds users whose first flagged message in the guild was
+// sent in [from, to), with their count for the week.
+func fetchNewEntrants(ctx context.Context, guildID string, from, to time.Time) ([]userScore, error) {
+	query := `SELECT m.userID, COUNT(*) FROM messages m
+		WHERE m.serverID = $1 AND m.timestamp >= $2 AND m.timestamp < $3
+		AND NOT EXISTS (SELECT 1 FROM messages p WHERE p.serverID = m.serverID AND p.userID = m.userID AND p.timestamp < $2)
+		GROUP BY m.userID ORDER BY 2 DESC, 1 LIMIT $4;`
+	rows, err := dbpool.Query(ctx, query, guildID, from, to, digestTop)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entrants []userScore
+	for rows.Next() {
+		var us userScore
+		if err := rows.Scan(&us.UserID, &us.MessageCount); err != nil {
+			return nil, err
+		}
+		entrants = append(entrants, us)
+	}
+	return entrants, rows.Err()
+}
+
+// fetchMostReacted returns the flagged message of the week with the most
+// reactions, if any got one.
+func fetchMostReacted(ctx context.Context, guildID string, from, to time.Time) (offense, string, int, bool, error) {
+	var o offense
+	var userID string
+	var reactions int
+	query := `SELECT serverID, COALESCE(channelID, ''), COALESCE(messageID, ''), COALESCE(message, ''), timestamp, userID, reactions FROM messages
+		WHERE serverID = $1 AND timestamp >= $2 AND timestamp < $3 AND reactions > 0
+		ORDER BY reactions DESC, timestamp LIMIT 1;`
+	err := dbpool.QueryRow(ctx, query, guildID, from, to).Scan(&o.ServerID, &o.ChannelID, &o.MessageID, &o.Message, &o.Timestamp, &userID, &reactions)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return o, "", 0, false, nil
+		}
+		return o, "", 0, false, err
+	}
+	return o, userID, reactions, true, nil
+}
+
+// weeklyMovers compares each user's count this week with last week, biggest
+// change first.
+func weeklyMovers(thisWeek, lastWeek []rankedScore) []mover {
+	deltas := map[string]int{}
+	for _, score := range thisWeek {
+		deltas[score.UserID] += score.MessageCount
+	}
+	for _, score := range lastWeek {
+		deltas[score.UserID] -= score.MessageCount
+	}
+
+	var movers []mover
+	for userID, delta := range deltas {
+		if delta != 0 {
+			movers = append(movers, mover{userID, delta})
+		}
+	}
+	sort.Slice(movers, func(a, b int) bool {
+		if abs(movers[a].Delta) != abs(movers[b].Delta) {
+			return abs(movers[a].Delta) > abs(movers[b].Delta)
+		}
+		return movers[a].UserID < movers[b].UserID
+	})
+	if len(movers) > digestTop {
+		movers = movers[:digestTop]
+	}
+	return movers
+}
+
+// buildDigest summarizes the week before end for a guild.
+func buildDigest(ctx context.Context, s *discordgo.Session, guildID string, end time.Time) (*discordgo.MessageEmbed, error) {
+	start := end.AddDate(0, 0, -7)
+	thisWeek := messageFilter{ServerID: guildID, From: start, To: end}
+	lastWeek := messageFilter{ServerID: guildID, From: start.AddDate(0, 0, -7), To: start}
+
+	scores, err := fetchScores(ctx, thisWeek)
+	if err != nil {
+		return nil, fmt.Errorf("fetching scores: %v", err)
+	}
+	previous, err := fetchScores(ctx, lastWeek)
+	if err != nil {
+		return nil, fmt.Errorf("fetching last week's scores: %v", err)
+	}
+	entrants, err := fetchNewEntrants(ctx, guildID, start, end)
+	if err != nil {
+		return nil, fmt.Errorf("fetching new entrants: %v", err)
+	}
+	words, err := fetchWordCounts(ctx, thisWeek, digestTop)
+	if err != nil {
+		return nil, fmt.Errorf("fetching words: %v", err)
+	}
+	reacted, reactedBy, reactions, hasReacted, err := fetchMostReacted(ctx, guildID, start, end)
+	if err != nil {
+		return nil, fmt.Errorf("fetching most reacted message: %v", err)
+	}
+
+	names := usernameCache{}
+	total := 0
+	for _, score := range scores {
+		total += score.MessageCount
+	}
+
+	var offenders []string
+	for _, score := range scores {
+		if len(offenders) == digestTop {
+			break
+		}
+		offenders = append(offenders, fmt.Sprintf("#%d %s: **%d**", score.Rank, names.get(s, score.UserID), score.MessageCount))
+	}
+
+	var newcomers []string
+	for _, entrant := range entrants {
+		newcomers = append(newcomers, fmt.Sprintf("%s: **%d**", names.get(s, entrant.UserID), entrant.MessageCount))
+	}
+
+	var topWords []string
+	for _, c := range words {
+		topWords = append(topWords, fmt.Sprintf("%s: **%d**", c.Word, c.Count))
+	}
+
+	var moved []string
+	for _, m := range weeklyMovers(scores, previous) {
+		arrow := "📈"
+		if m.Delta < 0 {
+			arrow = "📉"
+		}
+		moved = append(moved, fmt.Sprintf("%s %s: **%+d**", arrow, names.get(s, m.UserID), m.Delta))
+	}
+
+	orNone := func(lines []string, none string) string {
+		if len(lines) == 0 {
+			return none
+		}
+		return strings.Join(lines, "\n")
+	}
+
+	embed := &discordgo.MessageEmbed{
+		Title: "Weekly dirt digest",
+		Description: fmt.Sprintf("%s to %s: **%d** flagged messages from %d users.",
+			discordTimestamp(start, timestampLongDate), discordTimestamp(end.Add(-time.Second), timestampLongDate), total, len(scores)),
+		Color: 0xff0000,
+		Fields: []*discordgo.MessageEmbedField{
+			{Name: "Top offenders", Value: orNone(offenders, "A clean week. Suspicious."), Inline: true},
+			{Name: "Top words", Value: orNone(topWords, "None"), Inline: true},
+			{Name: "New entrants", Value: orNone(newcomers, "Nobody new fell from grace."), Inline: true},
+			{Name: "Biggest movers", Value: orNone(moved, "Nobody moved."), Inline: true},
+		},
+		Timestamp: end.Format(time.RFC3339),
+	}
+	if hasReacted {
+		embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{
+			Name:  fmt.Sprintf("Most reacted (%d reactions)", reactions),
+			Value: fmt.Sprintf("**%s** • %s\n> %s", names.get(s, reactedBy), reacted.when(), truncate(strings.ReplaceAll(reacted.Message, "\n", " "), 300)),
+		})
+	}
+	return embed, nil
+}
+
+// postDigest is the scheduled job posting the digest to the configured
+// channel.
+func postDigest(s *discordgo.Session, sched schedule, at time.Time) error {
+	if sched.ChannelID == "" {
+		return fmt.Errorf("no channel configured")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	embed, err := buildDigest(ctx, s, sched.ServerID, at)
+	if err != nil {
+		return err
+	}
+	_, err = s.ChannelMessageSendEmbed(sched.ChannelID, embed)
+	return err
+}
+
+func digestHandler(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	if err := acknowledgeInteraction(s, i); err != nil {
+		return
+	}
+
+	sub := i.ApplicationCommandData().Options[0]
+	if sub.Name == "preview" {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+
+		embed, err := buildDigest(ctx, s, i.GuildID, time.Now())
+		if err != nil {
+			log.Printf("Error building digest: %v", err)
+			if err := sendResponse(s, i, "Failed to build the digest"); err != nil {
+				log.Printf("Error sending detailed response: %v", err)
+			}
+			return
+		}
+		if err := sendEmbedResponse(s, i, embed); err != nil {
+			log.Printf("Error sending detailed response: %v", err)
+		}
+		return
+	}
+
+	response := "Skill issue"
+	adminUserID := os.Getenv("ADMIN_ID")
+
+	if i.Member.User.ID != adminUserID {
+		if err := sendResponse(s, i, response); err != nil {
+			log.Printf("Error sending detailed response: %v", err)
+		}
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	switch sub.Name {
+	case "setup":
+		options := optionsByName(sub.Options)
+		channelID := options["channel"].Value.(string)
+		day, hour := int64(1), int64(9)
+		if option, ok := options["day"]; ok {
+			day = option.IntValue()
+		}
+		if option, ok := options["hour"]; ok {
+			hour = option.IntValue()
+		}
+
+		next, err := saveSchedule(ctx, i.GuildID, digestJob, fmt.Sprintf("0 %d * * %d", hour, day), channelID)
+		if err != nil {
+			log.Printf("Error saving digest schedule: %v", err)
+			response = fmt.Sprintf("Failed to schedule the digest: %v", err)
+			break
+		}
+		recordAudit(i.GuildID, i.Member.User.ID, "digest setup", fmt.Sprintf("channel=%s day=%d hour=%d", channelID, day, hour))
+		response = fmt.Sprintf("The weekly digest goes to <#%s>, first on %s.", channelID, discordTimestamp(next, timestampShortDayTime))
+
+	case "off":
+		tag, err := dbpool.Exec(ctx, `DELETE FROM schedules WHERE serverid = $1 AND job = $2;`, i.GuildID, digestJob)
+		if err != nil {
+			log.Printf("Error deleting digest schedule: %v", err)
+			response = "Failed to turn the digest off"
+			break
+		}
+		response = "There was no digest to turn off."
+		if tag.RowsAffected() > 0 {
+			recordAudit(i.GuildID, i.Member.User.ID, "digest off", "")
+			response = "The weekly digest is off."
+		}
+	}
+
+	if err := sendResponse(s, i, response); err != nil {
+		log.Printf("Error sending detailed response: %v", err)
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+)
+
+// Colors shared by the rendered images.
+var (
+	backgroundColor = color.RGBA{0x31, 0x33, 0x38, 0xff} // Discord dark theme
+	foregroundColor = color.RGBA{0xf2, 0xf3, 0xf5, 0xff}
+	gridColor       = color.RGBA{0x4e, 0x50, 0x58, 0xff}
+	mutedColor      = color.RGBA{0xb5, 0xba, 0xc1, 0xff}
+)
+
+// paletteColors tell series and words apart, starting with the bot's red.
+var paletteColors = []color.RGBA{
+	{0xed, 0x42, 0x45, 0xff},
+	{0x57, 0xf2, 0x87, 0xff},
+	{0x58, 0x65, 0xf2, 0xff},
+	{0xfe, 0xe7, 0x5c, 0xff},
+	{0xeb, 0x45, 0x9e, 0xff},
+	{0x3b, 0xa5, 0x5d, 0xff},
+	{0xf0, 0x8c, 0x3a, 0xff},
+	{0x4f, 0xc3, 0xf7, 0xff},
+}
+
+func newCanvas(width, height int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(img, img.Bounds(), &image.Uniform{backgroundColor}, image.Point{}, draw.Src)
+	return img
+}
+
+func fillRect(img *image.RGBA, r image.Rectangle, c color.Color) {
+	draw.Draw(img, r.Intersect(img.Bounds()), &image.Uniform{c}, image.Point{}, draw.Over)
+}
+
+// drawLine draws a line of the given thickness between two points.
+func drawLine(img *image.RGBA, x0, y0, x1, y1, thickness int, c color.Color) {
+	dx, dy := abs(x1-x0), -abs(y1-y0)
+	sx, sy := 1, 1
+	if x0 > x1 {
+		sx = -1
+	}
+	if y0 > y1 {
+		sy = -1
+	}
+	half := thickness / 2
+	err := dx + dy
+	for {
+		fillRect(img, image.Rect(x0-half, y0-half, x0-half+thickness, y0-half+thickness), c)
+		if x0 == x1 && y0 == y1 {
+			return
+		}
+		e2 := 2 * err
+		if e2 >= dy {
+			err += dy
+			x0 += sx
+		}
+		if e2 <= dx {
+			err += dx
+			y0 += sy
+		}
+	}
+}
+
+func encodePNG(img image.Image) (*bytes.Buffer, error) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return &buf, nil
+}
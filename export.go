@@ -0,0 +1,210 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+const (
+	// exportPartSize is how large one exported file may grow, leaving room
+	// under Discord's 8 MiB upload limit for the rest of the request.
+	exportPartSize = 7 << 20
+	// exportMaxParts stops runaway exports, a narrower filter is needed then.
+	exportMaxParts = 20
+)
+
+type exportedMessage struct {
+	ID        int64     `json:"id"`
+	ServerID  string    `json:"server_id"`
+	ChannelID string    `json:"channel_id"`
+	MessageID string    `json:"message_id"`
+	UserID    string    `json:"user_id"`
+	Timestamp time.Time `json:"timestamp"`
+	Message   string    `json:"message"`
+	Words     []string  `json:"words"`
+}
+
+var exportContentTypes = map[string]string{
+	"csv":    "text/csv",
+	"json":   "application/json",
+	"ndjson": "application/x-ndjson",
+}
+
+// exportWriter encodes messages into numbered files of at most
+// exportPartSize bytes, handing each full file to flush. Every file stands on
+// its own: CSV files repeat the header and JSON files are complete arrays.
+type exportWriter struct {
+	format string
+	buf    bytes.Buffer
+	rows   int
+	parts  int
+	flush  func(name string, part *bytes.Buffer, rows int) error
+}
+
+func (w *exportWriter) write(m exportedMessage) error {
+	var record bytes.Buffer
+	switch w.format {
+	case "csv":
+		writer := csv.NewWriter(&record)
+		if w.rows == 0 {
+			writer.Write([]string{"id", "server_id", "channel_id", "message_id", "user_id", "timestamp", "message", "words"})
+		}
+		words, _ := json.Marshal(m.Words)
+		writer.Write([]string{fmt.Sprint(m.ID), m.ServerID, m.ChannelID, m.MessageID, m.UserID, m.Timestamp.UTC().Format(time.RFC3339), m.Message, string(words)})
+		writer.Flush()
+		if err := writer.Error(); err != nil {
+			return err
+		}
+	default:
+		if w.format == "json" {
+			if w.rows == 0 {
+				record.WriteString("[\n")
+			} else {
+				record.WriteString(",\n")
+			}
+		}
+		encoded, err := json.Marshal(m)
+		if err != nil {
+			return err
+		}
+		record.Write(encoded)
+		if w.format == "ndjson" {
+			record.WriteByte('\n')
+		}
+	}
+
+	if w.rows > 0 && w.buf.Len()+record.Len() > exportPartSize {
+		if err := w.close(); err != nil {
+			return err
+		}
+		return w.write(m)
+	}
+	w.buf.Write(record.Bytes())
+	w.rows++
+	return nil
+}
+
+// close flushes the file being written, if it has any rows.
+func (w *exportWriter) close() error {
+	if w.rows == 0 {
+		return nil
+	}
+	if w.format == "json" {
+		w.buf.WriteString("\n]\n")
+	}
+	w.parts++
+	if w.parts > exportMaxParts {
+		return fmt.Errorf("export is larger than %d files, narrow it down with the options", exportMaxParts)
+	}
+
+	name := fmt.Sprintf("messages-%03d.%s", w.parts, w.format)
+	part := bytes.NewBuffer(append([]byte(nil), w.buf.Bytes()...))
+	rows := w.rows
+	w.buf.Reset()
+	w.rows = 0
+	return w.flush(name, part, rows)
+}
+
+// exportHandler answers privately, so the exported messages only reach the
+// admin and not everyone in the channel.
+func exportHandler(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	if err := acknowledgeEphemeral(s, i); err != nil {
+		return
+	}
+
+	response := "Skill issue"
+	adminUserID := os.Getenv("ADMIN_ID")
+
+	if i.Member.User.ID != adminUserID {
+		if err := sendEphemeralResponse(s, i, response); err != nil {
+			log.Printf("Error sending detailed response: %v", err)
+		}
+		return
+	}
+
+	options := optionsByName(i.ApplicationCommandData().Options)
+	format := "csv"
+	if option, ok := options["format"]; ok {
+		format = option.StringValue()
+	}
+	filter := scopeFilter(i)
+	if option, ok := options["user"]; ok {
+		filter.UserID = option.UserValue(nil).ID
+	}
+	if err := filter.applyFilterOptions(options, getGuildSettings(i.GuildID).location()); err != nil {
+		if err := sendEphemeralResponse(s, i, err.Error()); err != nil {
+			log.Printf("Error sending detailed response: %v", err)
+		}
+		return
+	}
+
+	details := fmt.Sprintf("format=%s user=%s %s", format, filter.UserID, filter.describe())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	where, args := filter.where("m", nil)
+	query := fmt.Sprintf(`SELECT m.id, COALESCE(m.serverID, ''), COALESCE(m.channelID, ''), COALESCE(m.messageID, ''), m.userID, m.timestamp, COALESCE(m.message, ''),
+		ARRAY(SELECT w.word FROM words w WHERE w.wordID = ANY(m.wordID) ORDER BY w.word)
+		FROM messages m %s ORDER BY m.id;`, where)
+	rows, err := dbpool.Query(ctx, query, args...)
+	if err != nil {
+		log.Printf("Error executing export query: %v", err)
+		recordAudit(i.GuildID, i.Member.User.ID, "export", details+" failed: "+err.Error())
+		if err := sendEphemeralResponse(s, i, "Failed to export messages"); err != nil {
+			log.Printf("Error sending detailed response: %v", err)
+		}
+		return
+	}
+	defer rows.Close()
+
+	// Each file is sent as soon as it is full, so only one is held in memory.
+	total := 0
+	writer := &exportWriter{format: format, flush: func(name string, part *bytes.Buffer, count int) error {
+		total += count
+		file := &discordgo.File{
+			Name:        name,
+			ContentType: exportContentTypes[format],
+			Reader:      part,
+		}
+		return sendEphemeralResponse(s, i, fmt.Sprintf("%s: %d messages", name, count), file)
+	}}
+
+	for rows.Next() && err == nil {
+		var m exportedMessage
+		if err = rows.Scan(&m.ID, &m.ServerID, &m.ChannelID, &m.MessageID, &m.UserID, &m.Timestamp, &m.Message, &m.Words); err == nil {
+			err = writer.write(m)
+		}
+	}
+	if err == nil {
+		err = rows.Err()
+	}
+	if err == nil {
+		err = writer.close()
+	}
+	if err != nil {
+		log.Printf("Error exporting messages: %v", err)
+		recordAudit(i.GuildID, i.Member.User.ID, "export", fmt.Sprintf("%s failed after %d messages: %v", details, total, err))
+		if err := sendEphemeralResponse(s, i, fmt.Sprintf("Export stopped after %d messages: %v", total, err)); err != nil {
+			log.Printf("Error sending detailed response: %v", err)
+		}
+		return
+	}
+
+	recordAudit(i.GuildID, i.Member.User.ID, "export", fmt.Sprintf("%s messages=%d files=%d", details, total, writer.parts))
+	response = fmt.Sprintf("Exported %d messages in %d files.", total, writer.parts)
+	if total == 0 {
+		response = "No messages matched the export."
+	}
+	if err := sendEphemeralResponse(s, i, response); err != nil {
+		log.Printf("Error sending detailed response: %v", err)
+	}
+}
@@ -0,0 +1,255 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// dateLayout is the format date options are typed in.
+const dateLayout = "2006-01-02"
+
+// messageFilter narrows down the messages a command looks at. Empty fields
+// are not filtered on, so the zero value matches every stored message.
+type messageFilter struct {
+	ServerID  string
+	UserID    string
+	Word      string
+	Category  string
+	ChannelID string
+	From      time.Time // inclusive
+	To        time.Time // exclusive
+}
+
+// where returns a WHERE clause for the filter, appending its parameters to
+// args. alias is the name the messages table has in the query, if any.
+func (f messageFilter) where(alias string, args []interface{}) (string, []interface{}) {
+	if alias != "" {
+		alias += "."
+	}
+
+	var conditions []string
+	add := func(condition string, value interface{}) {
+		args = append(args, value)
+		conditions = append(conditions, fmt.Sprintf(condition, fmt.Sprintf("$%d", len(args))))
+	}
+
+	if f.ServerID != "" {
+		add(alias+"serverID = %s", f.ServerID)
+	}
+	if f.UserID != "" {
+		add(alias+"userID = %s", f.UserID)
+	}
+	if f.Word != "" {
+		add(alias+"wordID && ARRAY(SELECT wordID FROM words WHERE word = %s)", strings.ToLower(f.Word))
+	}
+	if f.Category != "" {
+		add(alias+"wordID && ARRAY(SELECT wordID FROM words WHERE category = %s)", f.Category)
+	}
+	if f.ChannelID != "" {
+		add(alias+"channelID = %s", f.ChannelID)
+	}
+	if !f.From.IsZero() {
+		add(alias+"timestamp >= %s", f.From)
+	}
+	if !f.To.IsZero() {
+		add(alias+"timestamp < %s", f.To)
+	}
+
+	if len(conditions) == 0 {
+		return "", args
+	}
+	return "WHERE " + strings.Join(conditions, " AND "), args
+}
+
+// describe summarizes the active filters for embed descriptions.
+func (f messageFilter) describe() string {
+	var parts []string
+	if f.Word != "" {
+		parts = append(parts, fmt.Sprintf("word **%s**", f.Word))
+	}
+	if f.Category != "" {
+		parts = append(parts, fmt.Sprintf("category **%s**", f.Category))
+	}
+	if f.ChannelID != "" {
+		parts = append(parts, fmt.Sprintf("in <#%s>", f.ChannelID))
+	}
+	if !f.From.IsZero() {
+		parts = append(parts, "from "+f.From.Format(dateLayout))
+	}
+	if !f.To.IsZero() {
+		parts = append(parts, "until "+f.To.Add(-time.Nanosecond).Format(dateLayout))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// scopeFilter limits commands to the current server, except on the main
+// server which sees every server.
+func scopeFilter(i *discordgo.InteractionCreate) messageFilter {
+	var filter messageFilter
+	if i.GuildID != os.Getenv("MAIN_SERVER") {
+		filter.ServerID = i.GuildID
+	}
+	return filter
+}
+
+// optionsByName indexes command options so optional ones can be looked up
+// without caring about their order.
+func optionsByName(options []*discordgo.ApplicationCommandInteractionDataOption) map[string]*discordgo.ApplicationCommandInteractionDataOption {
+	byName := make(map[string]*discordgo.ApplicationCommandInteractionDataOption, len(options))
+	for _, option := range options {
+		byName[option.Name] = option
+	}
+	return byName
+}
+
+// applyFilterOptions fills in the filter from the word, category, channel,
+// from and to options, whichever of them the command has. Dates are days in
+// loc, the time zone of the guild.
+func (f *messageFilter) applyFilterOptions(options map[string]*discordgo.ApplicationCommandInteractionDataOption, loc *time.Location) error {
+	if option, ok := options["word"]; ok {
+		f.Word = option.StringValue()
+	}
+	if option, ok := options["category"]; ok {
+		f.Category = option.StringValue()
+	}
+	if option, ok := options["channel"]; ok {
+		f.ChannelID = option.Value.(string)
+	}
+	if option, ok := options["from"]; ok {
+		from, err := time.ParseInLocation(dateLayout, option.StringValue(), loc)
+		if err != nil {
+			return fmt.Errorf("invalid from date %q, use YYYY-MM-DD", option.StringValue())
+		}
+		f.From = from
+	}
+	if option, ok := options["to"]; ok {
+		to, err := time.ParseInLocation(dateLayout, option.StringValue(), loc)
+		if err != nil {
+			return fmt.Errorf("invalid to date %q, use YYYY-MM-DD", option.StringValue())
+		}
+		f.To = to.AddDate(0, 0, 1)
+	}
+	if !f.From.IsZero() && !f.To.IsZero() && !f.From.Before(f.To) {
+		return fmt.Errorf("the from date must be before the to date")
+	}
+	return nil
+}
+
+// filterOptions are the command options understood by applyFilterOptions.
+func filterOptions() []*discordgo.ApplicationCommandOption {
+	return []*discordgo.ApplicationCommandOption{
+		{
+			Type:         discordgo.ApplicationCommandOptionString,
+			Name:         "word",
+			Description:  "Only messages with this word",
+			Autocomplete: true,
+		},
+		{
+			Type:         discordgo.ApplicationCommandOptionString,
+			Name:         "category",
+			Description:  "Only messages with a word from this category",
+			Autocomplete: true,
+		},
+		{
+			Type:         discordgo.ApplicationCommandOptionChannel,
+			Name:         "channel",
+			Description:  "Only messages sent in this channel",
+			ChannelTypes: []discordgo.ChannelType{discordgo.ChannelTypeGuildText},
+		},
+		{
+			Type:        discordgo.ApplicationCommandOptionString,
+			Name:        "from",
+			Description: "Only messages sent on or after this date (YYYY-MM-DD)",
+		},
+		{
+			Type:        discordgo.ApplicationCommandOptionString,
+			Name:        "to",
+			Description: "Only messages sent on or before this date (YYYY-MM-DD)",
+		},
+	}
+}
+
+// Periods a leaderboard can be limited to.
+const (
+	periodToday  = "today"
+	periodWeek   = "week"
+	periodMonth  = "month"
+	periodYear   = "year"
+	periodAll    = "all"
+	periodCustom = "custom"
+)
+
+func periodChoices() []*discordgo.ApplicationCommandOptionChoice {
+	var choices []*discordgo.ApplicationCommandOptionChoice
+	for _, period := range []string{periodToday, periodWeek, periodMonth, periodYear, periodAll, periodCustom} {
+		choices = append(choices, &discordgo.ApplicationCommandOptionChoice{Name: period, Value: period})
+	}
+	return choices
+}
+
+// periodStart returns when the given calendar period containing now began,
+// in now's location, which should be the guild's time zone. Weeks start on
+// Monday. The zero time is returned for periodAll.
+func periodStart(period string, now time.Time) time.Time {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	switch period {
+	case periodToday:
+		return today
+	case periodWeek:
+		return today.AddDate(0, 0, -(int(today.Weekday())+6)%7)
+	case periodMonth:
+		return time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+	case periodYear:
+		return time.Date(now.Year(), 1, 1, 0, 0, 0, 0, now.Location())
+	}
+	return time.Time{}
+}
+
+// describePeriod names the time window a filter covers, for embed texts.
+func describePeriod(period string, f messageFilter) string {
+	switch period {
+	case periodToday:
+		return "today"
+	case periodWeek:
+		return "this week (since " + f.From.Format("Jan 2") + ")"
+	case periodMonth:
+		return "this month (" + f.From.Format("January 2006") + ")"
+	case periodYear:
+		return "this year (" + f.From.Format("2006") + ")"
+	case periodCustom:
+		switch {
+		case !f.From.IsZero() && !f.To.IsZero():
+			return fmt.Sprintf("%s to %s", f.From.Format(dateLayout), f.To.Add(-time.Nanosecond).Format(dateLayout))
+		case !f.From.IsZero():
+			return "since " + f.From.Format(dateLayout)
+		case !f.To.IsZero():
+			return "until " + f.To.Add(-time.Nanosecond).Format(dateLayout)
+		}
+	}
+	return "all time"
+}
+
+// applyPeriodOption limits the filter to the period option. For custom
+// periods the from and to options, already applied, give the range.
+func (f *messageFilter) applyPeriodOption(options map[string]*discordgo.ApplicationCommandInteractionDataOption, now time.Time) (string, error) {
+	period := periodAll
+	if option, ok := options["period"]; ok {
+		period = option.StringValue()
+	}
+	_, hasFrom := options["from"]
+	_, hasTo := options["to"]
+
+	switch {
+	case period == periodCustom && !hasFrom && !hasTo:
+		return "", fmt.Errorf("a custom period needs a from or to date (YYYY-MM-DD)")
+	case period != periodCustom && (hasFrom || hasTo):
+		period = periodCustom
+	case period != periodAll && period != periodCustom:
+		f.From = periodStart(period, now)
+	}
+	return period, nil
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// Discord timestamp styles, rendered in the reader's own time zone and locale.
+const (
+	timestampLongDate     = 'D'
+	timestampShortDayTime = 'f'
+	timestampRelative     = 'R'
+)
+
+// discordTimestamp formats t with Discord's <t:unix:style> markup, so every
+// reader sees it in their own time zone.
+func discordTimestamp(t time.Time, style rune) string {
+	return fmt.Sprintf("<t:%d:%c>", t.Unix(), style)
+}
+
+// messageLink builds a jump link to a stored message. Messages stored before
+// channel and message IDs were recorded have no link.
+func messageLink(guildID, channelID, messageID string) string {
+	if guildID == "" || channelID == "" || messageID == "" {
+		return ""
+	}
+	return fmt.Sprintf("https://discord.com/channels/%s/%s/%s", guildID, channelID, messageID)
+}
+
+// offense is a single stored message as shown in listings.
+type offense struct {
+	ServerID  string
+	ChannelID string
+	MessageID string
+	Message   string
+	Timestamp time.Time
+}
+
+// when renders the time of the offense, linked to the message when possible.
+func (o offense) when() string {
+	when := discordTimestamp(o.Timestamp, timestampShortDayTime)
+	if link := messageLink(o.ServerID, o.ChannelID, o.MessageID); link != "" {
+		when = fmt.Sprintf("%s [↗](%s)", when, link)
+	}
+	return when
+}
+
+func (o offense) String() string {
+	return fmt.Sprintf("%s\n> %s", o.when(), truncate(o.Message, 200))
+}
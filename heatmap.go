@@ -0,0 +1,185 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"image/color"
+	"log"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+const (
+	heatmapCell   = 34
+	heatmapLeft   = 80
+	heatmapTop    = 56
+	heatmapLegend = 320
+)
+
+// heatmapStops is the color scale from an empty cell to the busiest one.
+var heatmapStops = []color.RGBA{
+	{0x3a, 0x3c, 0x43, 0xff},
+	{0xfe, 0xe7, 0x5c, 0xff},
+	{0xed, 0x42, 0x45, 0xff},
+}
+
+var weekdayLabels = [7]string{"Mon", "Tue", "Wed", "Thu", "Fri", "Sat", "Sun"}
+
+// heatColor picks the color for a cell at frac, between 0 and 1, of the
+// busiest cell.
+func heatColor(frac float64) color.RGBA {
+	if frac <= 0 {
+		return heatmapStops[0]
+	}
+	if frac >= 1 {
+		return heatmapStops[len(heatmapStops)-1]
+	}
+	pos := frac * float64(len(heatmapStops)-1)
+	k := int(pos)
+	t := pos - float64(k)
+	from, to := heatmapStops[k], heatmapStops[k+1]
+	mix := func(a, b uint8) uint8 {
+		return uint8(float64(a) + (float64(b)-float64(a))*t)
+	}
+	return color.RGBA{mix(from.R, to.R), mix(from.G, to.G), mix(from.B, to.B), 0xff}
+}
+
+// fetchHeatmap counts messages per weekday (Monday first) and hour in loc.
+func fetchHeatmap(ctx context.Context, filter messageFilter, loc *time.Location) ([7][24]int, error) {
+	var grid [7][24]int
+	where, args := filter.where("", nil)
+	args = append(args, loc.String())
+	query := fmt.Sprintf(`SELECT EXTRACT(ISODOW FROM timestamp AT TIME ZONE $%[1]d)::int, EXTRACT(HOUR FROM timestamp AT TIME ZONE $%[1]d)::int, COUNT(*)
+		FROM messages %[2]s GROUP BY 1, 2;`, len(args), where)
+	rows, err := dbpool.Query(ctx, query, args...)
+	if err != nil {
+		return grid, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var day, hour, count int
+		if err := rows.Scan(&day, &hour, &count); err != nil {
+			return grid, err
+		}
+		if day >= 1 && day <= 7 && hour >= 0 && hour < 24 {
+			grid[day-1][hour] = count
+		}
+	}
+	return grid, rows.Err()
+}
+
+// renderHeatmap draws the grid with weekdays as rows and hours as columns,
+// and a legend for the color scale below it.
+func renderHeatmap(title string, grid [7][24]int) *image.RGBA {
+	width := heatmapLeft + 24*heatmapCell + 20
+	height := heatmapTop + 7*heatmapCell + 80
+	img := newCanvas(width, height)
+	line := textHeight(chartScale)
+
+	drawText(img, 20, 16, title, chartScale, foregroundColor)
+
+	max := 0
+	for _, hours := range grid {
+		for _, count := range hours {
+			if count > max {
+				max = count
+			}
+		}
+	}
+
+	for day, hours := range grid {
+		y := heatmapTop + day*heatmapCell
+		drawText(img, 20, y+(heatmapCell-line)/2, weekdayLabels[day], chartScale, mutedColor)
+		for hour, count := range hours {
+			x := heatmapLeft + hour*heatmapCell
+			frac := 0.0
+			if max > 0 {
+				frac = float64(count) / float64(max)
+			}
+			fillRect(img, image.Rect(x+1, y+1, x+heatmapCell-1, y+heatmapCell-1), heatColor(frac))
+		}
+	}
+
+	bottom := heatmapTop + 7*heatmapCell
+	for hour := 0; hour < 24; hour += 3 {
+		label := fmt.Sprintf("%02d", hour)
+		x := heatmapLeft + hour*heatmapCell + (heatmapCell-textWidth(label, chartScale))/2
+		drawText(img, x, bottom+8, label, chartScale, mutedColor)
+	}
+
+	legendY := bottom + 24 + line
+	drawText(img, 20, legendY+2, "0", chartScale, mutedColor)
+	legendX := 20 + textWidth("0", chartScale) + 10
+	for x := 0; x < heatmapLegend; x++ {
+		fillRect(img, image.Rect(legendX+x, legendY, legendX+x+1, legendY+line+4), heatColor(float64(x)/float64(heatmapLegend-1)))
+	}
+	label := fmt.Sprintf("%d messages", max)
+	drawText(img, legendX+heatmapLegend+10, legendY+2, label, chartScale, mutedColor)
+	return img
+}
+
+func heatmapHandler(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	if err := acknowledgeInteraction(s, i); err != nil {
+		return
+	}
+
+	filter := scopeFilter(i)
+	name := "everyone"
+	if option, ok := optionsByName(i.ApplicationCommandData().Options)["user"]; ok {
+		user := option.UserValue(s)
+		filter.UserID = user.ID
+		name = user.Username
+	}
+
+	loc := getGuildSettings(i.GuildID).location()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	grid, err := fetchHeatmap(ctx, filter, loc)
+	if err != nil {
+		log.Printf("Error executing heatmap query: %v", err)
+		if err := sendResponse(s, i, "Failed to fetch activity"); err != nil {
+			log.Printf("Error sending detailed response: %v", err)
+		}
+		return
+	}
+
+	title := fmt.Sprintf("When %s gets dirty (%s)", name, loc.String())
+	buf, err := encodePNG(renderHeatmap(title, grid))
+	if err != nil {
+		log.Printf("Error rendering heatmap: %v", err)
+		if err := sendResponse(s, i, "Failed to render heatmap"); err != nil {
+			log.Printf("Error sending detailed response: %v", err)
+		}
+		return
+	}
+
+	// Point out the busiest slot in words as well.
+	bestDay, bestHour, total := 0, 0, 0
+	for day, hours := range grid {
+		for hour, count := range hours {
+			total += count
+			if count > grid[bestDay][bestHour] {
+				bestDay, bestHour = day, hour
+			}
+		}
+	}
+	response := fmt.Sprintf("No flagged messages from %s yet.", name)
+	if total > 0 {
+		response = fmt.Sprintf("Flagged messages from %s by hour, %s time. Peak: **%s %02d:00** with %d messages.",
+			name, loc.String(), weekdayLabels[bestDay], bestHour, grid[bestDay][bestHour])
+	}
+
+	file := &discordgo.File{
+		Name:        "heatmap.png",
+		ContentType: "image/png",
+		Reader:      buf,
+	}
+	if err := sendFileResponse(s, i, response, file); err != nil {
+		log.Printf("Error sending detailed response: %v", err)
+	}
+}
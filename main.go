@@ -32,11 +32,10 @@ var GuildID = flag.String("guild", "", "Test guild ID. If not passed - bot regis
 var RemoveCommands = flag.Bool("rmcmd", true, "Remove all commands after shutdowning or not")
 var s *discordgo.Session
 var dbpool *pgxpool.Pool
-var wordMap map[string]string
 
-func init() { flag.Parse() }
-
-func init() {
+// createSession loads the .env file and creates the Discord session. It runs
+// from main rather than init, so tests can load the package without a bot.
+func createSession() {
 
 	envErr := godotenv.Load()
 	if envErr != nil {
@@ -71,18 +70,37 @@ func connectToDB() {
 	loadWordMap()
 }
 
+var minLimit = 1.0
+var zeroHour = 0.0
+
 var commands = []*discordgo.ApplicationCommand{
 	{
 		Name:        "unholy",
 		Description: "Shows the unholy messages of a given user",
-		Options: []*discordgo.ApplicationCommandOption{
+		Options: append([]*discordgo.ApplicationCommandOption{
 			{
 				Type:        discordgo.ApplicationCommandOptionUser,
 				Name:        "user",
 				Description: "Select the user you want to lookup",
 				Required:    true,
 			},
-		},
+			{
+				Type:        discordgo.ApplicationCommandOptionString,
+				Name:        "sort",
+				Description: "The order of the messages, oldest first by default",
+				Choices: []*discordgo.ApplicationCommandOptionChoice{
+					{Name: sortOldest, Value: sortOldest},
+					{Name: sortNewest, Value: sortNewest},
+					{Name: sortRandom, Value: sortRandom},
+				},
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionInteger,
+				Name:        "limit",
+				Description: "The most messages to show",
+				MinValue:    &minLimit,
+			},
+		}, filterOptions()...),
 	},
 	{
 		Name:        "unholyadd",
@@ -101,24 +119,98 @@ var commands = []*discordgo.ApplicationCommand{
 		Description: "removees a word to the database",
 		Options: []*discordgo.ApplicationCommandOption{
 			{
-				Type:        discordgo.ApplicationCommandOptionString,
-				Name:        "word",
-				Description: "The word to be removed",
-				Required:    true,
+				Type:         discordgo.ApplicationCommandOptionString,
+				Name:         "word",
+				Description:  "The word to be removed",
+				Required:     true,
+				Autocomplete: true,
 			},
 		},
 	},
 	{
 		Name:        "scoreboard",
 		Description: "shows who has the most messages added to the database",
+		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Type:        discordgo.ApplicationCommandOptionString,
+				Name:        "period",
+				Description: "The time window to rank, all time by default",
+				Choices:     periodChoices(),
+			},
+			{
+				Type:         discordgo.ApplicationCommandOptionChannel,
+				Name:         "channel",
+				Description:  "Only count messages sent in this channel",
+				ChannelTypes: []discordgo.ChannelType{discordgo.ChannelTypeGuildText},
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionString,
+				Name:        "from",
+				Description: "Start of a custom period (YYYY-MM-DD)",
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionString,
+				Name:        "to",
+				Description: "End of a custom period (YYYY-MM-DD)",
+			},
+		},
 	},
 	{
 		Name:        "words",
-		Description: "shows all the words in the database",
+		Description: "shows, imports or exports the words in the database",
+		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+				Name:        "list",
+				Description: "Shows all the words in the database",
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+				Name:        "import",
+				Description: "Adds or updates words from a TXT, CSV or JSON file",
+				Options: []*discordgo.ApplicationCommandOption{
+					{
+						Type:        discordgo.ApplicationCommandOptionAttachment,
+						Name:        "file",
+						Description: "The word list, with optional mode, weight and category columns",
+						Required:    true,
+					},
+					{
+						Type:        discordgo.ApplicationCommandOptionBoolean,
+						Name:        "commit",
+						Description: "Apply the import instead of only previewing it",
+					},
+				},
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+				Name:        "export",
+				Description: "Sends the word list as a file",
+				Options: []*discordgo.ApplicationCommandOption{
+					{
+						Type:        discordgo.ApplicationCommandOptionString,
+						Name:        "format",
+						Description: "The file format, csv by default",
+						Choices: []*discordgo.ApplicationCommandOptionChoice{
+							{Name: "csv", Value: "csv"},
+							{Name: "json", Value: "json"},
+							{Name: "txt", Value: "txt"},
+						},
+					},
+				},
+			},
+		},
 	},
 	{
 		Name:        "commonwords",
 		Description: "shows which words has been used to most",
+		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Type:        discordgo.ApplicationCommandOptionUser,
+				Name:        "user",
+				Description: "Show the words of this user and their signature word",
+			},
+		},
 	},
 	{
 		Name:        "deleteallmessages",
@@ -128,6 +220,452 @@ var commands = []*discordgo.ApplicationCommand{
 		Name:        "help",
 		Description: "gives a small guide on how to use the bot",
 	},
+	{
+		Name:        "settings",
+		Description: "shows or changes the bot settings for this server",
+		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+				Name:        "show",
+				Description: "Shows the current settings",
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+				Name:        "stemming",
+				Description: "Matches inflected forms of the flagged words",
+				Options: []*discordgo.ApplicationCommandOption{
+					{
+						Type:        discordgo.ApplicationCommandOptionString,
+						Name:        "language",
+						Description: "The language used to stem words, or off",
+						Required:    true,
+						Choices:     stemLanguageChoices(),
+					},
+				},
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+				Name:        "timezone",
+				Description: "The time zone days and hours are counted in",
+				Options: []*discordgo.ApplicationCommandOption{
+					{
+						Type:        discordgo.ApplicationCommandOptionString,
+						Name:        "zone",
+						Description: "An IANA time zone such as Europe/Oslo",
+						Required:    true,
+					},
+				},
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+				Name:        "announcements",
+				Description: "Where the bot announces badges, leave out the channel to turn off",
+				Options: []*discordgo.ApplicationCommandOption{
+					{
+						Type:         discordgo.ApplicationCommandOptionChannel,
+						Name:         "channel",
+						Description:  "The channel to announce in",
+						ChannelTypes: []discordgo.ChannelType{discordgo.ChannelTypeGuildText},
+					},
+				},
+			},
+		},
+	},
+	{
+		Name:        "profile",
+		Description: "shows the full dirt profile of a user",
+		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Type:        discordgo.ApplicationCommandOptionUser,
+				Name:        "user",
+				Description: "The user to show, yourself by default",
+			},
+		},
+	},
+	{
+		Name:        "random",
+		Description: "pulls a random flagged message out of the database",
+		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Type:        discordgo.ApplicationCommandOptionUser,
+				Name:        "user",
+				Description: "Only messages from this user",
+			},
+			{
+				Type:         discordgo.ApplicationCommandOptionString,
+				Name:         "word",
+				Description:  "Only messages with this word",
+				Autocomplete: true,
+			},
+			{
+				Type:         discordgo.ApplicationCommandOptionChannel,
+				Name:         "channel",
+				Description:  "Only messages sent in this channel",
+				ChannelTypes: []discordgo.ChannelType{discordgo.ChannelTypeGuildText},
+			},
+		},
+	},
+	{
+		Name:        "search",
+		Description: "searches the text of the flagged messages",
+		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Type:        discordgo.ApplicationCommandOptionString,
+				Name:        "query",
+				Description: "Words to search for. Use \"quotes\" for phrases and -word to exclude",
+				Required:    true,
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionUser,
+				Name:        "user",
+				Description: "Only messages from this user",
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionString,
+				Name:        "from",
+				Description: "Only messages sent on or after this date (YYYY-MM-DD)",
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionString,
+				Name:        "to",
+				Description: "Only messages sent on or before this date (YYYY-MM-DD)",
+			},
+		},
+	},
+	{
+		Name:        "rank",
+		Description: "shows the position of a user and the gap to the people around them",
+		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Type:        discordgo.ApplicationCommandOptionUser,
+				Name:        "user",
+				Description: "The user to show, yourself by default",
+			},
+		},
+	},
+	{
+		Name:        "compare",
+		Description: "compares two users head to head",
+		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Type:        discordgo.ApplicationCommandOptionUser,
+				Name:        "user1",
+				Description: "The first user",
+				Required:    true,
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionUser,
+				Name:        "user2",
+				Description: "The second user",
+				Required:    true,
+			},
+		},
+	},
+	{
+		Name:        "chart",
+		Description: "draws the flagged messages over time as a chart",
+		Options: append([]*discordgo.ApplicationCommandOption{
+			{
+				Type:        discordgo.ApplicationCommandOptionString,
+				Name:        "interval",
+				Description: "How to group the messages, per day by default",
+				Choices: []*discordgo.ApplicationCommandOptionChoice{
+					{Name: intervalDay, Value: intervalDay},
+					{Name: intervalWeek, Value: intervalWeek},
+					{Name: intervalMonth, Value: intervalMonth},
+				},
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionString,
+				Name:        "style",
+				Description: "Draw lines or bars, lines by default",
+				Choices: []*discordgo.ApplicationCommandOptionChoice{
+					{Name: "line", Value: "line"},
+					{Name: "bar", Value: "bar"},
+				},
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionUser,
+				Name:        "user1",
+				Description: "Chart this user instead of the whole server",
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionUser,
+				Name:        "user2",
+				Description: "A second user to chart",
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionUser,
+				Name:        "user3",
+				Description: "A third user to chart",
+			},
+		}, filterOptions()...),
+	},
+	{
+		Name:        "heatmap",
+		Description: "draws when the flagged messages are sent by hour and weekday",
+		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Type:        discordgo.ApplicationCommandOptionUser,
+				Name:        "user",
+				Description: "Only messages from this user",
+			},
+		},
+	},
+	{
+		Name:        "wordcloud",
+		Description: "draws the flagged words as a word cloud",
+		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Type:        discordgo.ApplicationCommandOptionUser,
+				Name:        "user",
+				Description: "Only words from this user",
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionInteger,
+				Name:        "seed",
+				Description: "Lays the cloud out differently, the same seed gives the same cloud",
+			},
+		},
+	},
+	{
+		Name:        "export",
+		Description: "exports the stored messages as files",
+		Options: append([]*discordgo.ApplicationCommandOption{
+			{
+				Type:        discordgo.ApplicationCommandOptionString,
+				Name:        "format",
+				Description: "The file format, csv by default",
+				Choices: []*discordgo.ApplicationCommandOptionChoice{
+					{Name: "csv", Value: "csv"},
+					{Name: "json", Value: "json"},
+					{Name: "ndjson", Value: "ndjson"},
+				},
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionUser,
+				Name:        "user",
+				Description: "Only messages from this user",
+			},
+		}, filterOptions()...),
+	},
+	{
+		Name:        "schedule",
+		Description: "shows and pauses the recurring jobs of this server",
+		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+				Name:        "list",
+				Description: "Lists the schedules and when they run next",
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+				Name:        "pause",
+				Description: "Stops a schedule from running",
+				Options: []*discordgo.ApplicationCommandOption{
+					{
+						Type:        discordgo.ApplicationCommandOptionInteger,
+						Name:        "id",
+						Description: "The schedule number from /schedule list",
+						Required:    true,
+					},
+				},
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+				Name:        "resume",
+				Description: "Starts a paused schedule again",
+				Options: []*discordgo.ApplicationCommandOption{
+					{
+						Type:        discordgo.ApplicationCommandOptionInteger,
+						Name:        "id",
+						Description: "The schedule number from /schedule list",
+						Required:    true,
+					},
+				},
+			},
+		},
+	},
+	{
+		Name:        "digest",
+		Description: "the weekly dirt digest",
+		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+				Name:        "preview",
+				Description: "Shows the digest for the last seven days",
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+				Name:        "setup",
+				Description: "Posts the digest every week",
+				Options: []*discordgo.ApplicationCommandOption{
+					{
+						Type:         discordgo.ApplicationCommandOptionChannel,
+						Name:         "channel",
+						Description:  "The channel to post in",
+						ChannelTypes: []discordgo.ChannelType{discordgo.ChannelTypeGuildText},
+						Required:     true,
+					},
+					{
+						Type:        discordgo.ApplicationCommandOptionInteger,
+						Name:        "day",
+						Description: "The day to post on, Monday by default",
+						Choices:     weekdayChoices,
+					},
+					{
+						Type:        discordgo.ApplicationCommandOptionInteger,
+						Name:        "hour",
+						Description: "The hour to post at in the server's time zone, 9 by default",
+						MinValue:    &zeroHour,
+						MaxValue:    23,
+					},
+				},
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+				Name:        "off",
+				Description: "Stops posting the digest",
+			},
+		},
+	},
+	{
+		Name:        "onthisday",
+		Description: "flagged messages sent on this day in earlier years",
+		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+				Name:        "show",
+				Description: "Shows the messages sent on this day in earlier years",
+				Options: []*discordgo.ApplicationCommandOption{
+					{
+						Type:        discordgo.ApplicationCommandOptionUser,
+						Name:        "user",
+						Description: "Only messages from this user",
+					},
+				},
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+				Name:        "setup",
+				Description: "Posts the flashbacks every day there are any",
+				Options: []*discordgo.ApplicationCommandOption{
+					{
+						Type:         discordgo.ApplicationCommandOptionChannel,
+						Name:         "channel",
+						Description:  "The channel to post in",
+						ChannelTypes: []discordgo.ChannelType{discordgo.ChannelTypeGuildText},
+						Required:     true,
+					},
+					{
+						Type:        discordgo.ApplicationCommandOptionInteger,
+						Name:        "hour",
+						Description: "The hour to post at in the server's time zone, 12 by default",
+						MinValue:    &zeroHour,
+						MaxValue:    23,
+					},
+				},
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+				Name:        "off",
+				Description: "Stops the daily flashbacks",
+			},
+		},
+	},
+	{
+		Name:        "badges",
+		Description: "shows the badges a user has unlocked",
+		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Type:        discordgo.ApplicationCommandOptionUser,
+				Name:        "user",
+				Description: "The user to show, yourself by default",
+			},
+		},
+	},
+	{
+		Name:        "streaks",
+		Description: "days in a row with flagged messages",
+		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+				Name:        "top",
+				Description: "Shows the longest running and longest ever streaks",
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+				Name:        "notices",
+				Description: "Tells a channel when a long streak ends, leave out the channel to turn off",
+				Options: []*discordgo.ApplicationCommandOption{
+					{
+						Type:         discordgo.ApplicationCommandOptionChannel,
+						Name:         "channel",
+						Description:  "The channel to post in",
+						ChannelTypes: []discordgo.ChannelType{discordgo.ChannelTypeGuildText},
+					},
+				},
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+				Name:        "recompute",
+				Description: "Counts all streaks again from the stored messages",
+			},
+		},
+	},
+	{
+		Name:        "rolereward",
+		Description: "roles handed out automatically (admin only)",
+		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+				Name:        "add",
+				Description: "Hands out a role automatically",
+				Options: []*discordgo.ApplicationCommandOption{
+					{
+						Type:        discordgo.ApplicationCommandOptionRole,
+						Name:        "role",
+						Description: "The role to hand out",
+						Required:    true,
+					},
+					{
+						Type:        discordgo.ApplicationCommandOptionString,
+						Name:        "kind",
+						Description: "Who gets the role",
+						Required:    true,
+						Choices: []*discordgo.ApplicationCommandOptionChoice{
+							{Name: "#1 of last week", Value: rewardWeeklyTop},
+							{Name: "Entries threshold", Value: rewardThreshold},
+						},
+					},
+					{
+						Type:        discordgo.ApplicationCommandOptionInteger,
+						Name:        "threshold",
+						Description: "The number of entries a threshold reward needs",
+						MinValue:    &minLimit,
+					},
+				},
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+				Name:        "remove",
+				Description: "Stops handing out a role and takes it back",
+				Options: []*discordgo.ApplicationCommandOption{
+					{
+						Type:        discordgo.ApplicationCommandOptionRole,
+						Name:        "role",
+						Description: "The role to stop handing out",
+						Required:    true,
+					},
+				},
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+				Name:        "list",
+				Description: "Lists the role rewards and any problems handing them out",
+			},
+		},
+	},
 	// her kan neste komando være
 }
 
@@ -138,71 +676,38 @@ var commandHandlers = map[string]func(s *discordgo.Session, i *discordgo.Interac
 			return
 		}
 
-		user := i.ApplicationCommandData().Options[0].UserValue(s)
-		var response string
+		options := optionsByName(i.ApplicationCommandData().Options)
+		user := options["user"].UserValue(s)
 
 		serverID := i.GuildID
 		mainServer := os.Getenv("MAIN_SERVER")
 
-		var query string
-		if serverID != mainServer {
-			query = `SELECT serverID, userID, message, timestamp FROM messages WHERE userID = $1 AND serverID = $2 ORDER BY timestamp ASC`
-		} else {
-			query = `SELECT serverID, userID, message, timestamp FROM messages WHERE userID = $1 ORDER BY timestamp ASC`
-		}
-
-		var err error
-		var rows pgx.Rows
+		filter := messageFilter{UserID: user.ID}
 		if serverID != mainServer {
-			rows, err = dbpool.Query(context.Background(), query, user.ID, serverID)
-		} else {
-			rows, err = dbpool.Query(context.Background(), query, user.ID)
+			filter.ServerID = serverID
 		}
-
-		if err != nil {
-			response = fmt.Sprintf("Failed to query database: %v", err)
-			if err := sendResponse(s, i, response); err != nil {
+		if err := filter.applyFilterOptions(options, getGuildSettings(i.GuildID).location()); err != nil {
+			if err := sendResponse(s, i, err.Error()); err != nil {
 				log.Printf("Error sending detailed response: %v", err)
 			}
 			return
 		}
-		defer rows.Close()
-
-		var messages []string
-		for rows.Next() {
-			var serverID, userID, message string
-			var timestamp time.Time
-			if err := rows.Scan(&serverID, &userID, &message, &timestamp); err != nil {
-				log.Printf("Error scanning row: %v", err)
-				continue
-			}
-
-			guild, err := s.Guild(serverID)
-			if err != nil {
-				log.Printf("Error fetching guild: %v", err)
-				continue
-			}
 
-			messageStr := fmt.Sprintf("%s: %s: **%s**: %s", guild.Name, user.Username, message, timestamp.Format("2006-01-02 15:04:05"))
-			messages = append(messages, messageStr)
+		order := sortOldest
+		if option, ok := options["sort"]; ok {
+			order = option.StringValue()
+		}
+		limit := 0
+		if option, ok := options["limit"]; ok {
+			limit = int(option.IntValue())
 		}
 
-		if err := rows.Err(); err != nil {
-			log.Printf("Error processing results: %v", err)
-			response = fmt.Sprintf("Error processing results: %v", err)
+		render := unholyRenderer(s, user, filter, order, limit)
+		if err := sendPagedResponse(s, i, render); err != nil {
+			response := fmt.Sprintf("Failed to query database: %v", err)
 			if err := sendResponse(s, i, response); err != nil {
 				log.Printf("Error sending detailed response: %v", err)
 			}
-			return
-		}
-
-		response = strings.Join(messages, "\n")
-		if response == "" {
-			response = fmt.Sprintf("%v is a boring bitch. Gaslight them to join the list ;D", user.Username)
-		}
-
-		if err := sendResponse(s, i, response); err != nil {
-			log.Printf("Error sending detailed response: %v", err)
 		}
 	},
 
@@ -300,121 +805,38 @@ var commandHandlers = map[string]func(s *discordgo.Session, i *discordgo.Interac
 			return
 		}
 
-		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-		defer cancel()
-
 		serverID := i.GuildID
 		mainServer := os.Getenv("MAIN_SERVER")
 
-		var query string
+		options := optionsByName(i.ApplicationCommandData().Options)
+		var filter messageFilter
 		if serverID != mainServer {
-			query = `SELECT UserID, COUNT(*) AS message_count FROM messages WHERE serverID = $1 GROUP BY UserID ORDER BY message_count DESC;`
-		} else {
-			query = `SELECT UserID, COUNT(*) AS message_count FROM messages GROUP BY UserID ORDER BY message_count DESC;`
+			filter.ServerID = serverID
 		}
-
-		var err error
-		var rows pgx.Rows
-		if serverID != mainServer {
-			rows, err = dbpool.Query(ctx, query, serverID)
-		} else {
-			rows, err = dbpool.Query(ctx, query)
+		loc := getGuildSettings(serverID).location()
+		err := filter.applyFilterOptions(options, loc)
+		var period string
+		if err == nil {
+			period, err = filter.applyPeriodOption(options, time.Now().In(loc))
 		}
-
 		if err != nil {
-			log.Printf("Error executing scoreboard query: %v", err)
-			if err := sendResponse(s, i, "failed to fetch scoreboard"); err != nil {
+			if err := sendResponse(s, i, err.Error()); err != nil {
 				log.Printf("Error sending detailed response: %v", err)
 			}
 			return
 		}
 
-		if !rows.Next() {
-			if err := sendResponse(s, i, "No words has been recorded yet! be the first :D"); err != nil {
-				log.Printf("Error sending no data response: %v", err)
-			}
-			return
-		}
-
-		defer rows.Close()
-
-		var scores []userScore
-
-		for rows.Next() {
-			var us userScore
-			if err := rows.Scan(&us.UserID, &us.MessageCount); err != nil {
-				log.Printf("Error scanning scoreboard row: %v", err)
-				continue
-			}
-			scores = append(scores, us)
+		window := describePeriod(period, filter)
+		if filter.ChannelID != "" {
+			window += fmt.Sprintf(" in <#%s>", filter.ChannelID)
 		}
 
-		if err := rows.Err(); err != nil {
-			log.Printf("Error processing scoreboard results: %v", err)
-			if err := sendResponse(s, i, "Error processing scoreboard"); err != nil {
+		render := scoreboardRenderer(s, filter, window, interactionUserID(i))
+		if err := sendPagedResponse(s, i, render); err != nil {
+			log.Printf("Error executing scoreboard query: %v", err)
+			if err := sendResponse(s, i, "failed to fetch scoreboard"); err != nil {
 				log.Printf("Error sending detailed response: %v", err)
 			}
-			return
-		}
-
-		// Fetch usernames for each user ID
-		for index, score := range scores {
-			user, err := s.User(score.UserID)
-			if err != nil {
-				log.Printf("Error fetching user %s: %v", score.UserID, err)
-				scores[index].Username = "Unknown User"
-			} else {
-				scores[index].Username = user.Username
-			}
-		}
-
-		embed := &discordgo.MessageEmbed{
-			Title:       "Scoreboard",
-			Description: "Top message counts:",
-			Color:       0x00ff00, // Green color
-			Fields:      make([]*discordgo.MessageEmbedField, 0),
-			Timestamp:   time.Now().Format(time.RFC3339),
-		}
-
-		for i, score := range scores {
-			if i >= 3 {
-				break
-			}
-			icon := ""
-			switch i {
-			case 0:
-				icon = "🥇"
-			case 1:
-				icon = "🥈"
-			case 2:
-				icon = "🥉"
-			}
-			field := &discordgo.MessageEmbedField{
-				Name:   fmt.Sprintf("%s %s", icon, score.Username),
-				Value:  fmt.Sprintf("%d entries", score.MessageCount),
-				Inline: false,
-			}
-			embed.Fields = append(embed.Fields, field)
-		}
-
-		if len(scores) > 3 {
-			embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{
-				Name:   "More results",
-				Value:  "-------------------------",
-				Inline: false,
-			})
-			for _, score := range scores[3:] {
-				field := &discordgo.MessageEmbedField{
-					Name:   score.Username,
-					Value:  fmt.Sprintf("%d entries", score.MessageCount),
-					Inline: false,
-				}
-				embed.Fields = append(embed.Fields, field)
-			}
-		}
-
-		if err := sendEmbedResponse(s, i, embed); err != nil {
-			log.Printf("Error sending detailed response: %v", err)
 		}
 	},
 
@@ -424,6 +846,15 @@ var commandHandlers = map[string]func(s *discordgo.Session, i *discordgo.Interac
 			return // Stop if we can't even acknowledge the interaction
 		}
 
+		switch i.ApplicationCommandData().Options[0].Name {
+		case "import":
+			wordsImportHandler(s, i)
+			return
+		case "export":
+			wordsExportHandler(s, i)
+			return
+		}
+
 		var response string
 
 		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
@@ -480,6 +911,15 @@ var commandHandlers = map[string]func(s *discordgo.Session, i *discordgo.Interac
 		serverID := i.GuildID
 		mainServer := os.Getenv("MAIN_SERVER")
 
+		if option, ok := optionsByName(i.ApplicationCommandData().Options)["user"]; ok {
+			var filter messageFilter
+			if serverID != mainServer {
+				filter.ServerID = serverID
+			}
+			commonWordsForUser(s, i, option.UserValue(s), filter)
+			return
+		}
+
 		var query string
 		if serverID != mainServer {
 			query = `SELECT w.word, COUNT(*) AS usage_count FROM words w JOIN (SELECT UNNEST(wordID) AS wordID FROM messages WHERE serverID = $1) m ON w.wordID = m.wordID GROUP BY w.word ORDER BY usage_count DESC;`
@@ -584,13 +1024,39 @@ var commandHandlers = map[string]func(s *discordgo.Session, i *discordgo.Interac
 		# Welcome to **DirtOnYou**!
 		 
 		Ｃｏｍｍａｎｄｓ:
-		> **/unholy <user>**: This command will send all the _flagged_ messages of the given user, including information about when and where each message was sent.
+		> **/unholy <user> [word] [category] [channel] [from] [to] [sort] [limit]**: This command will send all the _flagged_ messages of the given user, including information about when and where each message was sent. Use the buttons to flip through the pages. Dates are written as YYYY-MM-DD and _sort_ can be oldest, newest or random.
+		
+		> **/scoreboard [period] [channel] [from] [to]**: This command displays a scoreboard with the amount of _flagged_ messages all users have, and your own position. _period_ can be today, week, month, year, all or custom, where custom uses _from_ and _to_ (YYYY-MM-DD).
+		
+		> **/words list**: This command will list all the _flagged_ words the bot monitors.
+		
+		> **/words export [format]**: Sends the _flagged_ words as a csv, json or txt file.
+		
+		> **/commonwords [user]**: This command will show all words that have been used and the frequency of their usage. With a user it shows their words, their share of each word and the _signature word_ they over-use the most.
+		
+		> **/profile [user]**: Shows the dirt profile of a user: rank, totals, favorite word, streaks and their first and latest offense.
+		
+		> **/random [user] [word] [channel]**: Pulls a random _flagged_ message out of the archives.
+		
+		> **/search <query> [user] [from] [to]**: Searches the text of the _flagged_ messages, best matches first. Use "quotes" for exact phrases and -word to leave a word out.
+		
+		> **/rank [user]**: Shows the position of a user and how far they are from the people above and below them.
+		
+		> **/compare <user1> <user2>**: Compares two users head to head: totals, trends, word differences and a winner.
+		
+		> **/chart [interval] [style] [user1] [user2] [user3]**: Draws the _flagged_ messages per day, week or month as a line or bar chart, for the whole server or up to three users. Takes the same filters as /unholy.
+		
+		> **/heatmap [user]**: Draws when the _flagged_ messages are sent, by hour and day of the week, in the server's time zone.
 		
-		> **/scoreboard**: This command displays a scoreboard with the amount of _flagged_ messages all users have.
+		> **/wordcloud [user] [seed]**: Draws the _flagged_ words as a word cloud, bigger for the words used the most. The same _seed_ always gives the same cloud.
 		
-		> **/words**: This command will list all the _flagged_ words the bot monitors.
+		> **/digest preview**: Shows the weekly digest for the last seven days: top offenders, top words, new entrants, biggest movers and the most reacted message.
 		
-		> **/commonwords**: This command will show all words that have been used and the frequency of their usage.
+		> **/onthisday show [user]**: Shows the _flagged_ messages sent on this day one, two or more years ago, in the server's time zone.
+		
+		> **/badges [user]**: Shows the badges a user has unlocked, like their first offense, 100 entries or topping the scoreboard of a finished week.
+		
+		> **/streaks top**: Shows who has _flagged_ messages the most days in a row right now, and the longest streaks ever. Days are counted in the server's time zone.
 		
 		> **/help**: Responds with _this_ message.
 		 
@@ -600,30 +1066,91 @@ var commandHandlers = map[string]func(s *discordgo.Session, i *discordgo.Interac
 		> **/unholyremove <word>**: Removes a word from the database and stops monitoring it. Previous messages logged with this word will not be deleted. To apply changes to old messages, use: _/deleteallmessages_.
 		
 		> **/deleteallmessages**: Deletes all messages in the database and starts backtracking the server. Note: this process is time-consuming due to Discord's limits, estimated at 5,600 messages per minute.
+		
+		> **/words import <file> [commit]**: Adds or updates words from a txt, csv or json file. Columns: word, mode (contains or exact), weight and category. Shows a preview unless _commit_ is set.
+		
+		> **/settings stemming <language>**: Also flags inflected forms of the words (english or norwegian), e.g. _ran_ for _run_. Use _off_ for plain matching.
+		
+		> **/settings announcements [channel]**: Announces newly unlocked badges in a channel. Leave out the channel to turn it off.
+		
+		> **/settings timezone <zone>**: Sets the time zone days and hours are counted in, e.g. _Europe/Oslo_. Defaults to UTC.
+		
+		> **/schedule list|pause|resume [id]**: Lists the recurring jobs of the server with their next run, or pauses and resumes one. Schedules run in the server's time zone, and a run missed while the bot was down happens once it is back.
+		
+		> **/digest setup <channel> [day] [hour]**: Posts the weekly digest to a channel, by default Mondays at 9 in the server's time zone. _/digest off_ stops it.
+		
+		> **/onthisday setup <channel> [hour]**: Posts the flashbacks to a channel every day there are any, by default at 12. _/onthisday off_ stops it.
+		
+		> **/streaks notices [channel]**: Posts in a channel when a streak of a week or more ends. Leave out the channel to turn it off.
+		
+		> **/streaks recompute**: Counts all streaks again from the stored messages. Backtracking and changing the time zone do this by themselves.
+		
+		> **/rolereward add <role> <kind> [threshold]**: Hands out a role automatically, either to last week's #1 or to everyone with at least _threshold_ entries. The roles are brought up to date every hour, and problems such as a missing Manage Roles permission are sent to the admin. _/rolereward remove <role>_ stops it and takes the role back, _/rolereward list_ shows them.
+		
+		> **/export [format] [user] [word] [from] [to]**: Sends the stored messages as csv, json or ndjson files, split into several files when they are too big for one upload. Every export is written to the audit log.
 		`
 
 		if err := sendResponse(s, i, response); err != nil {
 			log.Printf("Error sending detailed response: %v", err)
 		}
 	},
+	"settings":   settingsHandler,
+	"profile":    profileHandler,
+	"random":     randomHandler,
+	"search":     searchHandler,
+	"rank":       rankHandler,
+	"compare":    compareHandler,
+	"chart":      chartHandler,
+	"heatmap":    heatmapHandler,
+	"wordcloud":  wordcloudHandler,
+	"export":     exportHandler,
+	"schedule":   scheduleHandler,
+	"digest":     digestHandler,
+	"onthisday":  onThisDayHandler,
+	"badges":     badgesHandler,
+	"streaks":    streaksHandler,
+	"rolereward": roleRewardHandler,
 
 	// her kan neste commando være
 }
 
-func init() {
+func addInteractionHandler() {
 	s.AddHandler(func(s *discordgo.Session, i *discordgo.InteractionCreate) {
-		if h, ok := commandHandlers[i.ApplicationCommandData().Name]; ok {
-			h(s, i)
+		switch i.Type {
+		case discordgo.InteractionApplicationCommand:
+			if h, ok := commandHandlers[i.ApplicationCommandData().Name]; ok {
+				h(s, i)
+			}
+		case discordgo.InteractionApplicationCommandAutocomplete:
+			if h, ok := autocompleteHandlers[i.ApplicationCommandData().Name]; ok {
+				h(s, i)
+			}
+		case discordgo.InteractionMessageComponent:
+			prefix, _, _ := strings.Cut(i.MessageComponentData().CustomID, ":")
+			if h, ok := componentHandlers[prefix]; ok {
+				h(s, i)
+			}
 		}
 	})
 }
 
 func main() {
+	flag.Parse()
+	createSession()
+	addInteractionHandler()
 
 	connectToDB()
 	defer dbpool.Close()
 
+	listenCtx, stopListening := context.WithCancel(context.Background())
+	defer stopListening()
+	go listenForWordChanges(listenCtx)
+
 	s.AddHandler(messageCreate)
+	s.AddHandler(messageReactionAdd)
+	s.AddHandler(messageReactionRemove)
+	s.AddHandler(messageReactionRemoveAll)
+	s.AddHandler(guildCreate)
 	s.AddHandler(func(s *discordgo.Session, r *discordgo.Ready) {
 		log.Printf("Logged in as: %v#%v", s.State.User.Username, s.State.User.Discriminator)
 	})
@@ -632,6 +1159,8 @@ func main() {
 		log.Fatalf("Cannot open the session: %v", err)
 	}
 
+	go runScheduler(listenCtx, s)
+
 	log.Println("Adding commands...")
 	registeredCommands := make([]*discordgo.ApplicationCommand, len(commands))
 	for i, v := range commands {
@@ -676,86 +1205,73 @@ func messageCreate(s *discordgo.Session, m *discordgo.MessageCreate) {
 	if !serverExists {
 		go processAllMessages(s, m.GuildID)
 	} else {
-		processMessage(s, m.Message, m.GuildID)
+		processMessage(s, m.Message, m.GuildID, true)
 	}
 }
 
-func processMessage(s *discordgo.Session, msg *discordgo.Message, guildID string) {
-	if msg.Author.ID == s.State.User.ID {
-		return
-	}
-
-	if wordMap == nil {
-		return
-	}
+// guildCreate runs when the bot joins a guild and for every guild when it
+// connects, and makes sure the guild has the schedules every guild needs.
+func guildCreate(s *discordgo.Session, g *discordgo.GuildCreate) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
 
-	wordsInMessage := strings.Fields(strings.ToLower(msg.Content))
-	var wordIDs []string
-	for _, word := range wordsInMessage {
-		for key, id := range wordMap {
-			if strings.Contains(word, key) {
-				wordIDs = append(wordIDs, id)
-			}
-		}
+	if err := ensureSchedule(ctx, g.ID, badgesJob, badgesCron); err != nil {
+		log.Printf("Error scheduling weekly badges: %v", err)
 	}
+}
 
-	if len(wordIDs) == 0 {
+// processMessage stores a message if it is flagged. Live messages also count
+// towards streaks and badges right away; backtracking works those out in one
+// go when it is done.
+func processMessage(s *discordgo.Session, msg *discordgo.Message, guildID string, live bool) {
+	if msg.Author.ID == s.State.User.ID {
 		return
 	}
 
-	insertMessageIntoDB(msg, guildID, wordIDs)
-}
-
-func loadWordMap() {
-	newWordMap := make(map[string]string)
-	wordsQuery := `SELECT wordID, word FROM words;`
-	rows, err := dbpool.Query(context.Background(), wordsQuery)
-	if err != nil {
-		log.Printf("Error querying the database: %v", err)
+	matcher := currentMatcher.Load()
+	if matcher == nil {
 		return
 	}
-	defer rows.Close()
 
-	for rows.Next() {
-		var wordID, word string
-		if err := rows.Scan(&wordID, &word); err != nil {
-			log.Printf("Error scanning rows: %v", err)
-			return
-		}
-		newWordMap[word] = wordID
-	}
+	wordIDs := matcher.match(getGuildSettings(guildID).Stemming, msg.Content)
 
-	if err := rows.Err(); err != nil {
-		log.Printf("Error iterating rows: %v", err)
+	if len(wordIDs) == 0 {
 		return
 	}
 
-	wordMap = newWordMap
+	if insertMessageIntoDB(msg, guildID, wordIDs) && live {
+		updateStreak(msg, guildID)
+		checkAchievements(s, msg, guildID)
+	}
 }
 
-func insertMessageIntoDB(msg *discordgo.Message, guildID string, wordIDs []string) {
+// insertMessageIntoDB stores a flagged message and reports whether it was
+// stored.
+func insertMessageIntoDB(msg *discordgo.Message, guildID string, wordIDs []string) bool {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
 	tx, err := dbpool.Begin(ctx)
 	if err != nil {
 		log.Printf("Error starting transaction: %v", err)
-		return
+		return false
 	}
 	defer tx.Rollback(ctx)
 
 	msg.Content = strings.ReplaceAll(msg.Content, "@", "@\u200B")
 
-	insertQuery := `INSERT INTO messages (UserID, Message, ServerID, wordID, timestamp) VALUES ($1, $2, $3, $4, $5);`
-	_, err = tx.Exec(ctx, insertQuery, msg.Author.ID, msg.Content, guildID, wordIDs, msg.Timestamp)
+	insertQuery := `INSERT INTO messages (UserID, Message, ServerID, ChannelID, MessageID, wordID, timestamp, reactions) VALUES ($1, $2, $3, $4, $5, $6, $7, $8);`
+	_, err = tx.Exec(ctx, insertQuery, msg.Author.ID, msg.Content, guildID, msg.ChannelID, msg.ID, wordIDs, msg.Timestamp, reactionCount(msg))
 	if err != nil {
 		log.Printf("Error inserting message into database: %v", err)
-		return
+		return false
 	}
 
 	if err = tx.Commit(ctx); err != nil {
 		log.Printf("Error committing transaction: %v", err)
+		return false
 	}
+	return true
 }
 
 func checkServerExists(guildID string) (bool, error) {
@@ -813,7 +1329,7 @@ func processAllMessages(s *discordgo.Session, guildID string) {
 			}
 
 			for _, msg := range messages {
-				processMessage(s, msg, guildID)
+				processMessage(s, msg, guildID, false)
 			}
 
 			allMessages += len(messages)
@@ -831,6 +1347,14 @@ func processAllMessages(s *discordgo.Session, guildID string) {
 		totalMessageAmount = totalMessageAmount + allMessages
 	}
 
+	// Backtracking stores messages newest first, which the running streak
+	// counts cannot follow. Recomputing the streaks also awards the badges.
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+	if err := recomputeStreaks(ctx, guildID); err != nil {
+		log.Printf("Error recomputing streaks: %v", err)
+	}
+
 	m = fmt.Sprintf("Server **%v** has been backtracked. It took %v. Found a total of **%v** messages", guild.Name, time.Since(timeStart), totalMessageAmount)
 	sendAdminDM(m)
 }
@@ -878,6 +1402,45 @@ func sendEmbedResponse(s *discordgo.Session, i *discordgo.InteractionCreate, emb
 	return err
 }
 
+func sendFileResponse(s *discordgo.Session, i *discordgo.InteractionCreate, response string, files ...*discordgo.File) error {
+	_, err := s.FollowupMessageCreate(i.Interaction, true, &discordgo.WebhookParams{
+		Content: response,
+		Files:   files,
+	})
+	if err != nil {
+		log.Printf("Error sending follow-up file message: %v", err)
+	}
+	return err
+}
+
+// acknowledgeEphemeral defers the response like acknowledgeInteraction, but
+// only the invoking user sees it. Follow-ups must go through
+// sendEphemeralResponse to stay private.
+func acknowledgeEphemeral(s *discordgo.Session, i *discordgo.InteractionCreate) error {
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseDeferredChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{Flags: discordgo.MessageFlagsEphemeral},
+	})
+	if err != nil {
+		log.Printf("Error acknowledging interaction: %v", err)
+	}
+	return err
+}
+
+// sendEphemeralResponse sends a follow-up, with optional files, that only the
+// invoking user sees.
+func sendEphemeralResponse(s *discordgo.Session, i *discordgo.InteractionCreate, response string, files ...*discordgo.File) error {
+	_, err := s.FollowupMessageCreate(i.Interaction, true, &discordgo.WebhookParams{
+		Content: response,
+		Files:   files,
+		Flags:   discordgo.MessageFlagsEphemeral,
+	})
+	if err != nil {
+		log.Printf("Error sending ephemeral follow-up message: %v", err)
+	}
+	return err
+}
+
 func acknowledgeInteraction(s *discordgo.Session, i *discordgo.InteractionCreate) error {
 	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
 		Type: discordgo.InteractionResponseDeferredChannelMessageWithSource,
@@ -912,24 +1475,145 @@ CREATE EXTENSION IF NOT EXISTS "uuid-ossp";
 
 CREATE TABLE IF NOT EXISTS public.messages (
     userid character varying(255) COLLATE pg_catalog."default",
-    timestamp timestamp without time zone DEFAULT CURRENT_TIMESTAMP,
+    timestamp timestamp with time zone DEFAULT CURRENT_TIMESTAMP,
     message text COLLATE pg_catalog."default",
     serverid character varying(255) COLLATE pg_catalog."default",
-    wordid uuid[]
+    channelid character varying(255) COLLATE pg_catalog."default",
+    messageid character varying(255) COLLATE pg_catalog."default",
+    wordid uuid[],
+    id bigserial NOT NULL,
+    reactions integer NOT NULL DEFAULT 0,
+    CONSTRAINT messages_pkey PRIMARY KEY (id)
 );
 
+DO $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM information_schema.columns
+        WHERE table_schema = 'public' AND table_name = 'messages' AND column_name = 'timestamp'
+        AND data_type = 'timestamp without time zone') THEN
+        ALTER TABLE public.messages ALTER COLUMN timestamp TYPE timestamp with time zone USING timestamp AT TIME ZONE 'UTC';
+    END IF;
+END $$;
+ALTER TABLE public.messages ADD COLUMN IF NOT EXISTS channelid character varying(255) COLLATE pg_catalog."default";
+ALTER TABLE public.messages ADD COLUMN IF NOT EXISTS messageid character varying(255) COLLATE pg_catalog."default";
+ALTER TABLE public.messages ADD COLUMN IF NOT EXISTS id bigserial PRIMARY KEY;
+ALTER TABLE public.messages ADD COLUMN IF NOT EXISTS search tsvector
+    GENERATED ALWAYS AS (to_tsvector('simple', COALESCE(message, ''))) STORED;
+ALTER TABLE public.messages ADD COLUMN IF NOT EXISTS reactions integer NOT NULL DEFAULT 0;
+CREATE INDEX IF NOT EXISTS messages_search_idx ON public.messages USING GIN (search);
+CREATE INDEX IF NOT EXISTS messages_serverid_userid_timestamp_idx ON public.messages (serverid, userid, timestamp);
+CREATE INDEX IF NOT EXISTS messages_messageid_idx ON public.messages (messageid);
+CREATE INDEX IF NOT EXISTS messages_serverid_id_idx ON public.messages (serverid, id);
+
 
 CREATE TABLE IF NOT EXISTS public.words (
     wordid uuid NOT NULL DEFAULT uuid_generate_v4(),
     word character varying(255) COLLATE pg_catalog."default",
+    mode varchar(16) NOT NULL DEFAULT 'contains',
+    weight integer NOT NULL DEFAULT 1,
+    category varchar(64),
     CONSTRAINT words_pkey PRIMARY KEY (wordid),
     CONSTRAINT words_word_key UNIQUE (word)
 );
 
+ALTER TABLE public.words ADD COLUMN IF NOT EXISTS mode varchar(16) NOT NULL DEFAULT 'contains';
+ALTER TABLE public.words ADD COLUMN IF NOT EXISTS weight integer NOT NULL DEFAULT 1;
+ALTER TABLE public.words ADD COLUMN IF NOT EXISTS category varchar(64);
+
 CREATE TABLE IF NOT EXISTS public.servers (
     serverid varchar(255)
 )
 
+CREATE TABLE IF NOT EXISTS public.guild_settings (
+    serverid varchar(255) NOT NULL,
+    stemming varchar(16) NOT NULL DEFAULT 'off',
+    timezone varchar(64) NOT NULL DEFAULT 'UTC',
+    announcements varchar(255),
+    CONSTRAINT guild_settings_pkey PRIMARY KEY (serverid)
+);
+
+ALTER TABLE public.guild_settings ADD COLUMN IF NOT EXISTS timezone varchar(64) NOT NULL DEFAULT 'UTC';
+ALTER TABLE public.guild_settings ADD COLUMN IF NOT EXISTS announcements varchar(255);
+
+CREATE OR REPLACE FUNCTION public.notify_words_changed() RETURNS trigger AS $$
+BEGIN
+    PERFORM pg_notify('words_changed', TG_OP);
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE TRIGGER words_changed
+    AFTER INSERT OR UPDATE OR DELETE OR TRUNCATE ON public.words
+    FOR EACH STATEMENT EXECUTE FUNCTION public.notify_words_changed();
+
+CREATE TABLE IF NOT EXISTS public.audit_log (
+    id bigserial NOT NULL,
+    serverid varchar(255),
+    userid varchar(255) NOT NULL,
+    action varchar(64) NOT NULL,
+    details text,
+    created_at timestamp with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT audit_log_pkey PRIMARY KEY (id)
+);
+
+CREATE TABLE IF NOT EXISTS public.schedules (
+    id bigserial NOT NULL,
+    serverid varchar(255) NOT NULL,
+    job varchar(64) NOT NULL,
+    cron varchar(64) NOT NULL,
+    channelid varchar(255),
+    paused boolean NOT NULL DEFAULT false,
+    last_run timestamp with time zone,
+    next_run timestamp with time zone NOT NULL,
+    last_error text,
+    CONSTRAINT schedules_pkey PRIMARY KEY (id),
+    CONSTRAINT schedules_serverid_job_key UNIQUE (serverid, job)
+);
+
+CREATE INDEX IF NOT EXISTS schedules_next_run_idx ON public.schedules (next_run) WHERE NOT paused;
+
+CREATE TABLE IF NOT EXISTS public.achievements (
+    serverid varchar(255) NOT NULL,
+    userid varchar(255) NOT NULL,
+    achievement varchar(64) NOT NULL,
+    earned_at timestamp with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT achievements_pkey PRIMARY KEY (serverid, userid, achievement)
+);
+
+CREATE TABLE IF NOT EXISTS public.streaks (
+    serverid varchar(255) NOT NULL,
+    userid varchar(255) NOT NULL,
+    current integer NOT NULL DEFAULT 0,
+    best integer NOT NULL DEFAULT 0,
+    last_day date NOT NULL,
+    notified boolean NOT NULL DEFAULT false,
+    CONSTRAINT streaks_pkey PRIMARY KEY (serverid, userid)
+);
+
+CREATE TABLE IF NOT EXISTS public.role_rewards (
+    id bigserial NOT NULL,
+    serverid varchar(255) NOT NULL,
+    roleid varchar(255) NOT NULL,
+    kind varchar(16) NOT NULL,
+    threshold integer NOT NULL DEFAULT 0,
+    CONSTRAINT role_rewards_pkey PRIMARY KEY (id),
+    CONSTRAINT role_rewards_role_key UNIQUE (serverid, roleid)
+);
+
+CREATE TABLE IF NOT EXISTS public.role_reward_holders (
+    serverid varchar(255) NOT NULL,
+    roleid varchar(255) NOT NULL,
+    userid varchar(255) NOT NULL,
+    CONSTRAINT role_reward_holders_pkey PRIMARY KEY (serverid, roleid, userid)
+);
+
 ALTER TABLE IF EXISTS public.messages OWNER TO postgres;
 ALTER TABLE IF EXISTS public.words OWNER TO postgres;
+ALTER TABLE IF EXISTS public.guild_settings OWNER TO postgres;
+ALTER TABLE IF EXISTS public.audit_log OWNER TO postgres;
+ALTER TABLE IF EXISTS public.schedules OWNER TO postgres;
+ALTER TABLE IF EXISTS public.achievements OWNER TO postgres;
+ALTER TABLE IF EXISTS public.streaks OWNER TO postgres;
+ALTER TABLE IF EXISTS public.role_rewards OWNER TO postgres;
+ALTER TABLE IF EXISTS public.role_reward_holders OWNER TO postgres;
 */
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"log"
+	"strings"
+	"sync/atomic"
+	"unicode"
+)
+
+// Match modes of a flagged word. matchContains flags any token containing the
+// word, matchExact only tokens that are the word itself.
+const (
+	matchContains = "contains"
+	matchExact    = "exact"
+)
+
+var matchModes = []string{matchContains, matchExact}
+
+type flaggedWord struct {
+	ID       string
+	Word     string
+	Mode     string
+	Weight   int
+	Category string
+}
+
+// matches reports whether a single lowercase token of a message matches w.
+func (w flaggedWord) matches(token string) bool {
+	if w.Mode == matchExact {
+		return strings.TrimFunc(token, func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+		}) == w.Word
+	}
+	return strings.Contains(token, w.Word)
+}
+
+// wordMatcher is an immutable snapshot of the flagged words. A new one is
+// built on every reload and swapped in atomically, so message handlers can
+// keep matching against the old snapshot while a reload is in progress.
+type wordMatcher struct {
+	version uint64
+	words   []flaggedWord
+	stems   map[string]map[string][]string // language -> stem -> wordIDs
+}
+
+var currentMatcher atomic.Pointer[wordMatcher]
+var matcherVersion atomic.Uint64
+
+// match returns the IDs of the flagged words found in content. A word is
+// reported once for every token of the message it matches.
+func (m *wordMatcher) match(language, content string) []string {
+	stems := m.stems[language]
+
+	var wordIDs []string
+	if stems == nil {
+		wordsInMessage := strings.Fields(strings.ToLower(content))
+		for _, word := range wordsInMessage {
+			for _, flagged := range m.words {
+				if flagged.matches(word) {
+					wordIDs = append(wordIDs, flagged.ID)
+				}
+			}
+		}
+		return wordIDs
+	}
+
+	for _, word := range tokenize(content) {
+		matched := make(map[string]bool)
+		for _, flagged := range m.words {
+			if flagged.matches(word) {
+				matched[flagged.ID] = true
+			}
+		}
+		for _, id := range stems[stemWord(language, word)] {
+			matched[id] = true
+		}
+		for id := range matched {
+			wordIDs = append(wordIDs, id)
+		}
+	}
+	return wordIDs
+}
+
+// loadWordMap rebuilds the matcher from the words table. The version is taken
+// before querying, so when two reloads race the one that started last wins
+// even if it finishes first.
+func loadWordMap() {
+	version := matcherVersion.Add(1)
+
+	wordsQuery := `SELECT wordID, word, mode, weight, COALESCE(category, '') FROM words;`
+	rows, err := dbpool.Query(context.Background(), wordsQuery)
+	if err != nil {
+		log.Printf("Error querying the database: %v", err)
+		return
+	}
+	defer rows.Close()
+
+	var words []flaggedWord
+	for rows.Next() {
+		var w flaggedWord
+		if err := rows.Scan(&w.ID, &w.Word, &w.Mode, &w.Weight, &w.Category); err != nil {
+			log.Printf("Error scanning rows: %v", err)
+			return
+		}
+		words = append(words, w)
+	}
+
+	if err := rows.Err(); err != nil {
+		log.Printf("Error iterating rows: %v", err)
+		return
+	}
+
+	storeMatcher(newWordMatcher(version, words))
+}
+
+// newWordMatcher builds a snapshot of words with their stems in every
+// stemming language.
+func newWordMatcher(version uint64, words []flaggedWord) *wordMatcher {
+	m := &wordMatcher{
+		version: version,
+		words:   words,
+		stems:   make(map[string]map[string][]string),
+	}
+	for _, language := range stemLanguages {
+		if language == stemOff {
+			continue
+		}
+		stems := make(map[string][]string)
+		for _, w := range words {
+			stem := stemWord(language, w.Word)
+			stems[stem] = append(stems[stem], w.ID)
+		}
+		m.stems[language] = stems
+	}
+	return m
+}
+
+// storeMatcher swaps m in as the current matcher unless a newer version is
+// already in place, and reports whether it did.
+func storeMatcher(m *wordMatcher) bool {
+	for {
+		old := currentMatcher.Load()
+		if old != nil && old.version > m.version {
+			return false
+		}
+		if currentMatcher.CompareAndSwap(old, m) {
+			return true
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+)
+
+var (
+	oldWords = []flaggedWord{
+		{ID: "1", Word: "darn", Mode: matchContains, Weight: 1},
+		{ID: "2", Word: "heck", Mode: matchExact, Weight: 1},
+	}
+	newWords = []flaggedWord{
+		{ID: "3", Word: "blast", Mode: matchContains, Weight: 1},
+		{ID: "4", Word: "crud", Mode: matchExact, Weight: 1},
+	}
+)
+
+// matcherTestMessage contains every word of both sets once.
+const matcherTestMessage = "darn it, heck! blast this crud"
+
+func sortedMatch(m *wordMatcher, language string) []string {
+	ids := m.match(language, matcherTestMessage)
+	sort.Strings(ids)
+	return ids
+}
+
+// snapshotFor builds the old word set for odd versions and the new one for
+// even versions, so a snapshot's version tells what it must match.
+func snapshotFor(version uint64) *wordMatcher {
+	if version%2 == 1 {
+		return newWordMatcher(version, oldWords)
+	}
+	return newWordMatcher(version, newWords)
+}
+
+func restoreMatcher(t *testing.T) {
+	saved := currentMatcher.Load()
+	savedVersion := matcherVersion.Load()
+	t.Cleanup(func() {
+		currentMatcher.Store(saved)
+		matcherVersion.Store(savedVersion)
+	})
+}
+
+func TestWordMatcherMatch(t *testing.T) {
+	for _, language := range stemLanguages {
+		if got, want := sortedMatch(newWordMatcher(1, oldWords), language), []string{"1", "2"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: old words matched %v, want %v", language, got, want)
+		}
+		if got, want := sortedMatch(newWordMatcher(2, newWords), language), []string{"3", "4"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: new words matched %v, want %v", language, got, want)
+		}
+	}
+}
+
+func TestStoreMatcherKeepsNewestVersion(t *testing.T) {
+	restoreMatcher(t)
+	currentMatcher.Store(nil)
+
+	if !storeMatcher(snapshotFor(2)) {
+		t.Fatal("storing into an empty matcher failed")
+	}
+	if storeMatcher(snapshotFor(1)) {
+		t.Error("an older version replaced a newer one")
+	}
+	if got := currentMatcher.Load().version; got != 2 {
+		t.Errorf("current version is %d, want 2", got)
+	}
+	if !storeMatcher(snapshotFor(3)) {
+		t.Error("a newer version was refused")
+	}
+}
+
+// TestConcurrentReloads swaps snapshots while many goroutines match messages.
+// Run it with -race. Every match must see exactly one word set, the one of
+// the snapshot it loaded, and never a mix of both.
+func TestConcurrentReloads(t *testing.T) {
+	restoreMatcher(t)
+	matcherVersion.Store(0)
+	currentMatcher.Store(nil)
+	storeMatcher(snapshotFor(matcherVersion.Add(1)))
+
+	const (
+		reloaders = 4
+		reloads   = 200
+		matchers  = 16
+		matches   = 500
+	)
+	oldIDs, newIDs := []string{"1", "2"}, []string{"3", "4"}
+
+	var wg sync.WaitGroup
+	for r := 0; r < reloaders; r++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for k := 0; k < reloads; k++ {
+				storeMatcher(snapshotFor(matcherVersion.Add(1)))
+			}
+		}()
+	}
+
+	errs := make(chan string, matchers)
+	for g := 0; g < matchers; g++ {
+		wg.Add(1)
+		go func(language string) {
+			defer wg.Done()
+			for k := 0; k < matches; k++ {
+				m := currentMatcher.Load()
+				want := newIDs
+				if m.version%2 == 1 {
+					want = oldIDs
+				}
+				if got := sortedMatch(m, language); !reflect.DeepEqual(got, want) {
+					errs <- language + ": matched a mix of word sets"
+					return
+				}
+			}
+		}(stemLanguages[g%len(stemLanguages)])
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Error(err)
+	}
+	if got, want := currentMatcher.Load().version, matcherVersion.Load(); got != want {
+		t.Errorf("current version is %d after all reloads, want the newest %d", got, want)
+	}
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+)
+
+// wordsChannel is the channel the words table trigger notifies on, see the
+// schema at the bottom of main.go.
+const wordsChannel = "words_changed"
+
+// listenForWordChanges reloads the matcher whenever the words table changes,
+// whether that is from another bot instance or from someone editing it in SQL.
+// It runs until ctx is cancelled and reconnects with backoff if the connection
+// drops.
+func listenForWordChanges(ctx context.Context) {
+	backoff := time.Second
+	for {
+		connected, err := listenForNotifications(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		if connected {
+			backoff = time.Second
+		}
+		log.Printf("Word list listener stopped: %v. Reconnecting in %v", err, backoff)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		if backoff < time.Minute {
+			backoff *= 2
+		}
+	}
+}
+
+// listenForNotifications uses its own connection rather than one from the pool,
+// since a LISTENing connection must not be handed out to other queries.
+func listenForNotifications(ctx context.Context) (bool, error) {
+	conn, err := pgx.ConnectConfig(ctx, dbpool.Config().ConnConfig)
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close(context.Background())
+
+	if _, err := conn.Exec(ctx, "LISTEN "+pgx.Identifier{wordsChannel}.Sanitize()); err != nil {
+		return false, err
+	}
+	log.Printf("Listening for word list changes on %q", wordsChannel)
+
+	// Anything that changed while we were disconnected was never notified.
+	loadWordMap()
+
+	for {
+		if _, err := conn.WaitForNotification(ctx); err != nil {
+			return true, err
+		}
+		loadWordMap()
+	}
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// onThisDayJob is the name of the daily flashback post in the schedules table.
+const onThisDayJob = "onthisday"
+
+// onThisDayPerYear is how many messages are shown for each earlier year, the
+// most reacted first, and onThisDayYears how many years back at most, which
+// keeps the embed within Discord's size limits.
+const (
+	onThisDayPerYear = 3
+	onThisDayYears   = 5
+)
+
+type flashback struct {
+	offense
+	UserID string
+	Year   int
+}
+
+// fetchFlashbacks finds messages sent on the same month and day as day in
+// earlier years, counting days in day's location.
+func fetchFlashbacks(ctx context.Context, filter messageFilter, day time.Time) ([]flashback, error) {
+	where, args := filter.where("", nil)
+	args = append(args, day.Location().String(), int(day.Month()), day.Day(), day.Year())
+	n := len(args)
+	and := "WHERE"
+	if where != "" {
+		and = where + " AND"
+	}
+	query := fmt.Sprintf(`SELECT serverID, channelID, messageID, message, timestamp, userID, year FROM (
+			SELECT serverID, COALESCE(channelID, '') AS channelID, COALESCE(messageID, '') AS messageID, COALESCE(message, '') AS message, timestamp, userID,
+				EXTRACT(YEAR FROM timestamp AT TIME ZONE $%[1]d)::int AS year,
+				ROW_NUMBER() OVER (PARTITION BY EXTRACT(YEAR FROM timestamp AT TIME ZONE $%[1]d) ORDER BY reactions DESC, timestamp) AS position
+			FROM messages %[5]s EXTRACT(MONTH FROM timestamp AT TIME ZONE $%[1]d) = $%[2]d
+				AND EXTRACT(DAY FROM timestamp AT TIME ZONE $%[1]d) = $%[3]d
+				AND EXTRACT(YEAR FROM timestamp AT TIME ZONE $%[1]d) < $%[4]d
+		) flashbacks WHERE position <= %[6]d ORDER BY year DESC, position;`, n-3, n-2, n-1, n, and, onThisDayPerYear)
+
+	rows, err := dbpool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var flashbacks []flashback
+	for rows.Next() {
+		var f flashback
+		if err := rows.Scan(&f.ServerID, &f.ChannelID, &f.MessageID, &f.Message, &f.Timestamp, &f.UserID, &f.Year); err != nil {
+			return nil, err
+		}
+		flashbacks = append(flashbacks, f)
+	}
+	return flashbacks, rows.Err()
+}
+
+// onThisDayEmbed shows the flashbacks grouped by how many years ago they are.
+func onThisDayEmbed(s *discordgo.Session, day time.Time, flashbacks []flashback) *discordgo.MessageEmbed {
+	names := usernameCache{}
+	embed := &discordgo.MessageEmbed{
+		Title: fmt.Sprintf("On this day, %s", day.Format("2 January")),
+		Color: 0xff0000,
+	}
+
+	var lines []string
+	for k, f := range flashbacks {
+		if len(embed.Fields) == onThisDayYears {
+			break
+		}
+		lines = append(lines, fmt.Sprintf("**%s** • %s\n> %s", names.get(s, f.UserID), f.when(), truncate(strings.ReplaceAll(f.Message, "\n", " "), 200)))
+		if k+1 == len(flashbacks) || flashbacks[k+1].Year != f.Year {
+			ago := day.Year() - f.Year
+			name := fmt.Sprintf("%d years ago (%d)", ago, f.Year)
+			if ago == 1 {
+				name = fmt.Sprintf("1 year ago (%d)", f.Year)
+			}
+			embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{Name: name, Value: truncate(strings.Join(lines, "\n"), 1024)})
+			lines = nil
+		}
+	}
+	if len(embed.Fields) == 0 {
+		embed.Description = "Nothing unholy happened on this day in earlier years. Yet."
+	}
+	return embed
+}
+
+// postOnThisDay is the scheduled job posting the day's flashbacks. Days
+// without any are skipped quietly.
+func postOnThisDay(s *discordgo.Session, sched schedule, at time.Time) error {
+	if sched.ChannelID == "" {
+		return fmt.Errorf("no channel configured")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	day := at.In(getGuildSettings(sched.ServerID).location())
+	flashbacks, err := fetchFlashbacks(ctx, messageFilter{ServerID: sched.ServerID}, day)
+	if err != nil || len(flashbacks) == 0 {
+		return err
+	}
+	_, err = s.ChannelMessageSendEmbed(sched.ChannelID, onThisDayEmbed(s, day, flashbacks))
+	return err
+}
+
+func onThisDayHandler(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	if err := acknowledgeInteraction(s, i); err != nil {
+		return
+	}
+
+	sub := i.ApplicationCommandData().Options[0]
+	if sub.Name == "show" {
+		filter := scopeFilter(i)
+		if option, ok := optionsByName(sub.Options)["user"]; ok {
+			filter.UserID = option.UserValue(nil).ID
+		}
+		day := time.Now().In(getGuildSettings(i.GuildID).location())
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		flashbacks, err := fetchFlashbacks(ctx, filter, day)
+		if err != nil {
+			log.Printf("Error executing onthisday query: %v", err)
+			if err := sendResponse(s, i, "Failed to fetch flashbacks"); err != nil {
+				log.Printf("Error sending detailed response: %v", err)
+			}
+			return
+		}
+		if err := sendEmbedResponse(s, i, onThisDayEmbed(s, day, flashbacks)); err != nil {
+			log.Printf("Error sending detailed response: %v", err)
+		}
+		return
+	}
+
+	response := "Skill issue"
+	adminUserID := os.Getenv("ADMIN_ID")
+
+	if i.Member.User.ID != adminUserID {
+		if err := sendResponse(s, i, response); err != nil {
+			log.Printf("Error sending detailed response: %v", err)
+		}
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	switch sub.Name {
+	case "setup":
+		options := optionsByName(sub.Options)
+		channelID := options["channel"].Value.(string)
+		hour := int64(12)
+		if option, ok := options["hour"]; ok {
+			hour = option.IntValue()
+		}
+
+		next, err := saveSchedule(ctx, i.GuildID, onThisDayJob, fmt.Sprintf("0 %d * * *", hour), channelID)
+		if err != nil {
+			log.Printf("Error saving onthisday schedule: %v", err)
+			response = fmt.Sprintf("Failed to schedule the flashbacks: %v", err)
+			break
+		}
+		recordAudit(i.GuildID, i.Member.User.ID, "onthisday setup", fmt.Sprintf("channel=%s hour=%d", channelID, hour))
+		response = fmt.Sprintf("Flashbacks go to <#%s> every day there are any, first on %s.", channelID, discordTimestamp(next, timestampShortDayTime))
+
+	case "off":
+		tag, err := dbpool.Exec(ctx, `DELETE FROM schedules WHERE serverid = $1 AND job = $2;`, i.GuildID, onThisDayJob)
+		if err != nil {
+			log.Printf("Error deleting onthisday schedule: %v", err)
+			response = "Failed to turn the flashbacks off"
+			break
+		}
+		response = "There were no daily flashbacks to turn off."
+		if tag.RowsAffected() > 0 {
+			recordAudit(i.GuildID, i.Member.User.ID, "onthisday off", "")
+			response = "The daily flashbacks are off."
+		}
+	}
+
+	if err := sendResponse(s, i, response); err != nil {
+		log.Printf("Error sending detailed response: %v", err)
+	}
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// pageSessionTTL is how long the buttons of a paged response keep working.
+const pageSessionTTL = 10 * time.Minute
+
+// pageRenderer renders one page (counting from 0) and reports how many pages
+// there are in total.
+type pageRenderer func(page int) (*discordgo.MessageEmbed, int, error)
+
+// Every click is handled in its own goroutine. A session's renders take turns
+// through mu, since renderers keep caches that are not safe for concurrent use.
+type pageSession struct {
+	ownerID string
+	render  pageRenderer
+	expires time.Time
+
+	mu sync.Mutex
+}
+
+var pageSessionsMu sync.Mutex
+var pageSessions = map[string]*pageSession{}
+
+// componentHandlers serve button clicks by the prefix of their custom ID, the
+// part before the first ':'.
+var componentHandlers = map[string]func(s *discordgo.Session, i *discordgo.InteractionCreate){
+	"page": pageComponentHandler,
+}
+
+func newPageSession(ownerID string, render pageRenderer) string {
+	id := make([]byte, 6)
+	rand.Read(id)
+	sessionID := hex.EncodeToString(id)
+
+	pageSessionsMu.Lock()
+	defer pageSessionsMu.Unlock()
+
+	now := time.Now()
+	for key, session := range pageSessions {
+		if now.After(session.expires) {
+			delete(pageSessions, key)
+		}
+	}
+	pageSessions[sessionID] = &pageSession{
+		ownerID: ownerID,
+		render:  render,
+		expires: now.Add(pageSessionTTL),
+	}
+	return sessionID
+}
+
+func getPageSession(sessionID string) *pageSession {
+	pageSessionsMu.Lock()
+	defer pageSessionsMu.Unlock()
+
+	session, ok := pageSessions[sessionID]
+	if !ok || time.Now().After(session.expires) {
+		delete(pageSessions, sessionID)
+		return nil
+	}
+	return session
+}
+
+// pageButtons builds the navigation row. The target page is part of every
+// custom ID, so the bot does not have to remember which page is showing.
+func pageButtons(sessionID string, page, totalPages int, disabled bool) []discordgo.MessageComponent {
+	button := func(action, label string, target int) discordgo.Button {
+		return discordgo.Button{
+			Label:    label,
+			Style:    discordgo.SecondaryButton,
+			CustomID: fmt.Sprintf("page:%s:%s:%d", sessionID, action, target),
+			Disabled: disabled || target == page || target < 0 || target >= totalPages,
+		}
+	}
+	return []discordgo.MessageComponent{
+		discordgo.ActionsRow{
+			Components: []discordgo.MessageComponent{
+				button("first", "⏮", 0),
+				button("prev", "◀", page-1),
+				button("next", "▶", page+1),
+				button("last", "⏭", totalPages-1),
+			},
+		},
+	}
+}
+
+// sendPagedResponse sends the first page as a follow-up, with navigation
+// buttons when there is more than one page.
+func sendPagedResponse(s *discordgo.Session, i *discordgo.InteractionCreate, render pageRenderer) error {
+	embed, totalPages, err := render(0)
+	if err != nil {
+		return err
+	}
+
+	params := &discordgo.WebhookParams{
+		Embeds: []*discordgo.MessageEmbed{embed},
+	}
+	if totalPages > 1 {
+		sessionID := newPageSession(interactionUserID(i), render)
+		params.Components = pageButtons(sessionID, 0, totalPages, false)
+	}
+
+	_, err = s.FollowupMessageCreate(i.Interaction, true, params)
+	if err != nil {
+		log.Printf("Error sending follow-up embed message: %v", err)
+	}
+	return err
+}
+
+func pageComponentHandler(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	parts := strings.Split(i.MessageComponentData().CustomID, ":")
+	if len(parts) != 4 {
+		return
+	}
+	page, err := strconv.Atoi(parts[3])
+	if err != nil {
+		return
+	}
+
+	session := getPageSession(parts[1])
+	if session == nil {
+		components := pageButtons(parts[1], 0, 1, true)
+		err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseUpdateMessage,
+			Data: &discordgo.InteractionResponseData{
+				Content:    "This view has expired, run the command again to browse it.",
+				Embeds:     i.Message.Embeds,
+				Components: components,
+			},
+		})
+		if err != nil {
+			log.Printf("Error responding to expired page: %v", err)
+		}
+		return
+	}
+
+	if interactionUserID(i) != session.ownerID {
+		err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseChannelMessageWithSource,
+			Data: &discordgo.InteractionResponseData{
+				Content: "Only the person who ran the command can turn the pages.",
+				Flags:   discordgo.MessageFlagsEphemeral,
+			},
+		})
+		if err != nil {
+			log.Printf("Error responding to page click: %v", err)
+		}
+		return
+	}
+
+	session.mu.Lock()
+	embed, totalPages, err := session.render(page)
+	session.mu.Unlock()
+	if err != nil {
+		log.Printf("Error rendering page %d: %v", page, err)
+		embed = &discordgo.MessageEmbed{Description: "Failed to load this page."}
+	}
+	if page >= totalPages {
+		page = totalPages - 1
+	}
+
+	err = s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseUpdateMessage,
+		Data: &discordgo.InteractionResponseData{
+			Embeds:     []*discordgo.MessageEmbed{embed},
+			Components: pageButtons(parts[1], page, totalPages, false),
+		},
+	})
+	if err != nil {
+		log.Printf("Error updating page: %v", err)
+	}
+}
+
+// interactionUserID returns who triggered the interaction, both in guilds and
+// in DMs.
+func interactionUserID(i *discordgo.InteractionCreate) string {
+	if i.Member != nil {
+		return i.Member.User.ID
+	}
+	if i.User != nil {
+		return i.User.ID
+	}
+	return ""
+}
+
+// pageFooter describes the page position for the embed footer.
+func pageFooter(page, totalPages, total int, noun string) *discordgo.MessageEmbedFooter {
+	return &discordgo.MessageEmbedFooter{
+		Text: fmt.Sprintf("Page %d/%d • %d %s", page+1, totalPages, total, noun),
+	}
+}
+
+// truncate shortens s to at most n runes, marking the cut with an ellipsis.
+func truncate(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n-1]) + "…"
+}
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/jackc/pgx/v4"
+)
+
+type dirtProfile struct {
+	Total         int
+	Weighted      int
+	First, Latest offense
+	FavoriteWord  string
+	FavoriteCount int
+	Channel       string
+	ChannelCount  int
+	BusiestHour   int
+	HourCount     int
+	CurrentStreak int
+	LongestStreak int
+	Rank          int
+	Ranked        int
+}
+
+// fetchProfile gathers everything /profile shows for the messages of filter,
+// which must have UserID set. The rank is among all users matching the rest of
+// the filter, and the busiest hour is counted in loc.
+func fetchProfile(ctx context.Context, filter messageFilter, loc *time.Location) (dirtProfile, error) {
+	var p dirtProfile
+	where, args := filter.where("m", nil)
+
+	if err := dbpool.QueryRow(ctx, `SELECT COUNT(*) FROM messages m `+where, args...).Scan(&p.Total); err != nil {
+		return p, fmt.Errorf("counting messages: %v", err)
+	}
+	if p.Total == 0 {
+		return p, nil
+	}
+
+	rows, err := dbpool.Query(ctx, `SELECT w.word, COUNT(*), SUM(w.weight) FROM messages m
+		CROSS JOIN LATERAL UNNEST(m.wordID) AS u(wordID) JOIN words w ON w.wordID = u.wordID `+where+`
+		GROUP BY w.word ORDER BY COUNT(*) DESC, w.word;`, args...)
+	if err != nil {
+		return p, fmt.Errorf("counting words: %v", err)
+	}
+	for rows.Next() {
+		var word string
+		var count, weighted int
+		if err := rows.Scan(&word, &count, &weighted); err != nil {
+			rows.Close()
+			return p, fmt.Errorf("scanning words: %v", err)
+		}
+		if p.FavoriteWord == "" {
+			p.FavoriteWord, p.FavoriteCount = word, count
+		}
+		p.Weighted += weighted
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return p, fmt.Errorf("counting words: %v", err)
+	}
+
+	offenseQuery := `SELECT m.serverID, COALESCE(m.channelID, ''), COALESCE(m.messageID, ''), COALESCE(m.message, ''), m.timestamp FROM messages m ` + where + ` ORDER BY m.timestamp %s LIMIT 1;`
+	for _, o := range []struct {
+		order  string
+		target *offense
+	}{{"ASC", &p.First}, {"DESC", &p.Latest}} {
+		err := dbpool.QueryRow(ctx, fmt.Sprintf(offenseQuery, o.order), args...).Scan(
+			&o.target.ServerID, &o.target.ChannelID, &o.target.MessageID, &o.target.Message, &o.target.Timestamp)
+		if err != nil {
+			return p, fmt.Errorf("fetching offense: %v", err)
+		}
+	}
+
+	err = dbpool.QueryRow(ctx, `SELECT m.channelID, COUNT(*) FROM messages m `+where+` AND m.channelID IS NOT NULL
+		GROUP BY m.channelID ORDER BY COUNT(*) DESC LIMIT 1;`, args...).Scan(&p.Channel, &p.ChannelCount)
+	if err != nil && err != pgx.ErrNoRows {
+		return p, fmt.Errorf("finding channel: %v", err)
+	}
+
+	hourArgs := append(append([]interface{}{}, args...), loc.String())
+	hour := fmt.Sprintf("EXTRACT(HOUR FROM m.timestamp AT TIME ZONE $%d)::int", len(hourArgs))
+	err = dbpool.QueryRow(ctx, `SELECT `+hour+` AS hour, COUNT(*) FROM messages m `+where+`
+		GROUP BY hour ORDER BY COUNT(*) DESC LIMIT 1;`, hourArgs...).Scan(&p.BusiestHour, &p.HourCount)
+	if err != nil {
+		return p, fmt.Errorf("finding busiest hour: %v", err)
+	}
+
+	p.CurrentStreak, p.LongestStreak, err = fetchStreaks(ctx, filter.ServerID, filter.UserID, time.Now())
+	if err != nil {
+		return p, err
+	}
+
+	rankFilter := filter
+	rankFilter.UserID = ""
+	rankWhere, rankArgs := rankFilter.where("", nil)
+	rankArgs = append(rankArgs, p.Total)
+	err = dbpool.QueryRow(ctx, fmt.Sprintf(`SELECT COUNT(*) FILTER (WHERE total > $%d) + 1, COUNT(*) FROM
+		(SELECT COUNT(*) AS total FROM messages %s GROUP BY userID) t;`, len(rankArgs), rankWhere), rankArgs...).Scan(&p.Rank, &p.Ranked)
+	if err != nil {
+		return p, fmt.Errorf("ranking user: %v", err)
+	}
+
+	return p, nil
+}
+
+// fetchStreaks reads a user's current and longest streaks from the streaks
+// table, in one guild or, with an empty guildID, the best of all guilds. Like
+// /streaks, a streak is still current if its last day is today or yesterday
+// in its guild's time zone.
+func fetchStreaks(ctx context.Context, guildID, userID string, now time.Time) (int, int, error) {
+	rows, err := dbpool.Query(ctx, `SELECT serverid, current, best, last_day FROM streaks
+		WHERE userid = $1 AND ($2 = '' OR serverid = $2);`, userID, guildID)
+	if err != nil {
+		return 0, 0, fmt.Errorf("fetching streaks: %v", err)
+	}
+	defer rows.Close()
+
+	current, longest := 0, 0
+	for rows.Next() {
+		var serverID string
+		var st streak
+		if err := rows.Scan(&serverID, &st.Current, &st.Best, &st.LastDay); err != nil {
+			return 0, 0, fmt.Errorf("scanning streaks: %v", err)
+		}
+		if st.Best > longest {
+			longest = st.Best
+		}
+		yesterday := guildDay(serverID, now).AddDate(0, 0, -1)
+		if !st.LastDay.Before(yesterday) && st.Current > current {
+			current = st.Current
+		}
+	}
+	return current, longest, rows.Err()
+}
+
+func profileHandler(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	if err := acknowledgeInteraction(s, i); err != nil {
+		return
+	}
+
+	var user *discordgo.User
+	if option, ok := optionsByName(i.ApplicationCommandData().Options)["user"]; ok {
+		user = option.UserValue(s)
+	} else if i.Member != nil {
+		user = i.Member.User
+	} else {
+		user = i.User
+	}
+
+	serverID := i.GuildID
+	mainServer := os.Getenv("MAIN_SERVER")
+
+	filter := messageFilter{UserID: user.ID}
+	if serverID != mainServer {
+		filter.ServerID = serverID
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	loc := getGuildSettings(i.GuildID).location()
+	p, err := fetchProfile(ctx, filter, loc)
+	if err != nil {
+		log.Printf("Error fetching profile: %v", err)
+		if err := sendResponse(s, i, "Failed to fetch profile"); err != nil {
+			log.Printf("Error sending detailed response: %v", err)
+		}
+		return
+	}
+
+	if p.Total == 0 {
+		response := fmt.Sprintf("%v is a boring bitch. Gaslight them to join the list ;D", user.Username)
+		if err := sendResponse(s, i, response); err != nil {
+			log.Printf("Error sending detailed response: %v", err)
+		}
+		return
+	}
+
+	channel := "Unknown"
+	if p.Channel != "" {
+		channel = fmt.Sprintf("<#%s> (%d)", p.Channel, p.ChannelCount)
+	}
+
+	embed := &discordgo.MessageEmbed{
+		Title:     fmt.Sprintf("Dirt profile of %s", user.Username),
+		Color:     0xff0000,
+		Thumbnail: &discordgo.MessageEmbedThumbnail{URL: user.AvatarURL("128")},
+		Fields: []*discordgo.MessageEmbedField{
+			{Name: "Rank", Value: fmt.Sprintf("#%d of %d", p.Rank, p.Ranked), Inline: true},
+			{Name: "Entries", Value: fmt.Sprintf("%d", p.Total), Inline: true},
+			{Name: "Weighted score", Value: fmt.Sprintf("%d", p.Weighted), Inline: true},
+			{Name: "Favorite word", Value: fmt.Sprintf("%s (%d)", p.FavoriteWord, p.FavoriteCount), Inline: true},
+			{Name: "Most active channel", Value: channel, Inline: true},
+			{Name: "Busiest hour", Value: fmt.Sprintf("%02d:00–%02d:00 %s (%d)", p.BusiestHour, (p.BusiestHour+1)%24, loc, p.HourCount), Inline: true},
+			{Name: "Current streak", Value: fmt.Sprintf("%d days", p.CurrentStreak), Inline: true},
+			{Name: "Longest streak", Value: fmt.Sprintf("%d days", p.LongestStreak), Inline: true},
+			{Name: "First offense", Value: p.First.String()},
+			{Name: "Latest offense", Value: p.Latest.String()},
+		},
+		Timestamp: time.Now().Format(time.RFC3339),
+	}
+
+	if err := sendEmbedResponse(s, i, embed); err != nil {
+		log.Printf("Error sending detailed response: %v", err)
+	}
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math/rand"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/jackc/pgx/v4"
+)
+
+// randomAttempts is how often randomMessage tries again when the message it
+// picked was deleted between counting and fetching.
+const randomAttempts = 3
+
+// randomMessage picks a random stored message matching filter, every match
+// equally likely. It counts the matches and skips a random number of them in
+// id order. Picking a random id instead would favour messages after gaps in
+// the ids, and ORDER BY random() sorts every match. The cost is a count and
+// an offset scan over the matches, which the (serverid, id) index keeps to
+// the guild's own messages; filters on user, word or channel scan more of them.
+func randomMessage(ctx context.Context, filter messageFilter) (offense, string, bool, error) {
+	var o offense
+	var userID string
+
+	where, args := filter.where("", nil)
+	query := fmt.Sprintf(`SELECT serverID, COALESCE(channelID, ''), COALESCE(messageID, ''), userID, COALESCE(message, ''), timestamp FROM messages %s
+		ORDER BY id LIMIT 1 OFFSET $%d;`, where, len(args)+1)
+
+	for attempt := 0; attempt < randomAttempts; attempt++ {
+		var count int64
+		if err := dbpool.QueryRow(ctx, `SELECT COUNT(*) FROM messages `+where, args...).Scan(&count); err != nil {
+			return o, "", false, fmt.Errorf("counting messages: %v", err)
+		}
+		if count == 0 {
+			return o, "", false, nil
+		}
+
+		err := dbpool.QueryRow(ctx, query, append(args, rand.Int63n(count))...).Scan(
+			&o.ServerID, &o.ChannelID, &o.MessageID, &userID, &o.Message, &o.Timestamp)
+		if err == pgx.ErrNoRows {
+			continue
+		}
+		if err != nil {
+			return o, "", false, fmt.Errorf("fetching message: %v", err)
+		}
+		return o, userID, true, nil
+	}
+	return o, "", false, nil
+}
+
+func randomHandler(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	if err := acknowledgeInteraction(s, i); err != nil {
+		return
+	}
+
+	serverID := i.GuildID
+	mainServer := os.Getenv("MAIN_SERVER")
+
+	options := optionsByName(i.ApplicationCommandData().Options)
+	var filter messageFilter
+	if serverID != mainServer {
+		filter.ServerID = serverID
+	}
+	if option, ok := options["user"]; ok {
+		filter.UserID = option.UserValue(nil).ID
+	}
+	if err := filter.applyFilterOptions(options, getGuildSettings(i.GuildID).location()); err != nil {
+		if err := sendResponse(s, i, err.Error()); err != nil {
+			log.Printf("Error sending detailed response: %v", err)
+		}
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	quote, userID, found, err := randomMessage(ctx, filter)
+	if err != nil {
+		log.Printf("Error fetching random message: %v", err)
+		if err := sendResponse(s, i, "Failed to fetch a random message"); err != nil {
+			log.Printf("Error sending detailed response: %v", err)
+		}
+		return
+	}
+	if !found {
+		if err := sendResponse(s, i, "No messages found. Gaslight someone to join the list ;D"); err != nil {
+			log.Printf("Error sending detailed response: %v", err)
+		}
+		return
+	}
+
+	author := &discordgo.MessageEmbedAuthor{Name: "Unknown User"}
+	if user, err := s.User(userID); err != nil {
+		log.Printf("Error fetching user %s: %v", userID, err)
+	} else {
+		author = &discordgo.MessageEmbedAuthor{Name: user.Username, IconURL: user.AvatarURL("64")}
+	}
+
+	embed := &discordgo.MessageEmbed{
+		Author:      author,
+		Description: "> " + strings.ReplaceAll(truncate(quote.Message, 2000), "\n", "\n> "),
+		Color:       0xff0000,
+		Timestamp:   quote.Timestamp.Format(time.RFC3339),
+	}
+	if link := messageLink(quote.ServerID, quote.ChannelID, quote.MessageID); link != "" {
+		embed.Title = "Jump to message"
+		embed.URL = link
+	}
+	if quote.ChannelID != "" {
+		embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{Name: "Channel", Value: fmt.Sprintf("<#%s>", quote.ChannelID), Inline: true})
+	}
+	embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{Name: "Date", Value: discordTimestamp(quote.Timestamp, timestampLongDate), Inline: true})
+
+	if err := sendEmbedResponse(s, i, embed); err != nil {
+		log.Printf("Error sending detailed response: %v", err)
+	}
+}
@@ -0,0 +1,249 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+func rankHandler(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	if err := acknowledgeInteraction(s, i); err != nil {
+		return
+	}
+
+	userID := interactionUserID(i)
+	if option, ok := optionsByName(i.ApplicationCommandData().Options)["user"]; ok {
+		userID = option.UserValue(nil).ID
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	scores, err := fetchScores(ctx, scopeFilter(i))
+	if err != nil {
+		log.Printf("Error executing rank query: %v", err)
+		if err := sendResponse(s, i, "Failed to fetch rank"); err != nil {
+			log.Printf("Error sending detailed response: %v", err)
+		}
+		return
+	}
+
+	names := usernameCache{}
+	position := -1
+	for k, score := range scores {
+		if score.UserID == userID {
+			position = k
+			break
+		}
+	}
+	if position < 0 {
+		response := fmt.Sprintf("%v is a boring bitch. Gaslight them to join the list ;D", names.get(s, userID))
+		if err := sendResponse(s, i, response); err != nil {
+			log.Printf("Error sending detailed response: %v", err)
+		}
+		return
+	}
+	me := scores[position]
+
+	embed := &discordgo.MessageEmbed{
+		Title:       fmt.Sprintf("Rank of %s", names.get(s, userID)),
+		Description: fmt.Sprintf("**#%d** of %d with **%d** entries", me.Rank, len(scores), me.MessageCount),
+		Color:       0x00ff00,
+	}
+
+	// The closest users with a different count, skipping anyone tied.
+	for k := position - 1; k >= 0; k-- {
+		if above := scores[k]; above.MessageCount > me.MessageCount {
+			embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{
+				Name:   "Next up",
+				Value:  fmt.Sprintf("#%d %s, **%d** entries ahead", above.Rank, names.get(s, above.UserID), above.MessageCount-me.MessageCount),
+				Inline: true,
+			})
+			break
+		}
+	}
+	for k := position + 1; k < len(scores); k++ {
+		if below := scores[k]; below.MessageCount < me.MessageCount {
+			embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{
+				Name:   "Next down",
+				Value:  fmt.Sprintf("#%d %s, **%d** entries behind", below.Rank, names.get(s, below.UserID), me.MessageCount-below.MessageCount),
+				Inline: true,
+			})
+			break
+		}
+	}
+	if me.Rank == 1 {
+		embed.Footer = &discordgo.MessageEmbedFooter{Text: "The dirtiest of them all 👑"}
+	}
+
+	if err := sendEmbedResponse(s, i, embed); err != nil {
+		log.Printf("Error sending detailed response: %v", err)
+	}
+}
+
+type wordComparison struct {
+	Word   string
+	First  int
+	Second int
+}
+
+// compareWords counts how often each of the two users used every word.
+func compareWords(ctx context.Context, filter messageFilter, first, second string) ([]wordComparison, error) {
+	where, args := filter.where("m", nil)
+	args = append(args, first, second)
+	and := "WHERE"
+	if where != "" {
+		and = where + " AND"
+	}
+	query := fmt.Sprintf(`SELECT w.word, COUNT(*) FILTER (WHERE m.userID = $%[1]d), COUNT(*) FILTER (WHERE m.userID = $%[2]d)
+		FROM messages m CROSS JOIN LATERAL UNNEST(m.wordID) AS u(wordID) JOIN words w ON w.wordID = u.wordID
+		%[3]s m.userID IN ($%[1]d, $%[2]d) GROUP BY w.word;`, len(args)-1, len(args), and)
+
+	rows, err := dbpool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var words []wordComparison
+	for rows.Next() {
+		var c wordComparison
+		if err := rows.Scan(&c.Word, &c.First, &c.Second); err != nil {
+			return nil, err
+		}
+		words = append(words, c)
+	}
+	sort.Slice(words, func(a, b int) bool {
+		diffA, diffB := abs(words[a].First-words[a].Second), abs(words[b].First-words[b].Second)
+		if diffA != diffB {
+			return diffA > diffB
+		}
+		return words[a].Word < words[b].Word
+	})
+	return words, rows.Err()
+}
+
+// comparePeriods counts the messages of both users in each calendar period.
+func comparePeriods(ctx context.Context, filter messageFilter, first, second string, now time.Time) (map[string][2]int, error) {
+	counts := make(map[string][2]int)
+	for _, period := range []string{periodToday, periodWeek, periodMonth, periodYear, periodAll} {
+		periodFilter := filter
+		periodFilter.From = periodStart(period, now)
+
+		where, args := periodFilter.where("", nil)
+		args = append(args, first, second)
+		and := "WHERE"
+		if where != "" {
+			and = where + " AND"
+		}
+		query := fmt.Sprintf(`SELECT COUNT(*) FILTER (WHERE userID = $%[1]d), COUNT(*) FILTER (WHERE userID = $%[2]d)
+			FROM messages %[3]s userID IN ($%[1]d, $%[2]d);`, len(args)-1, len(args), and)
+
+		var pair [2]int
+		if err := dbpool.QueryRow(ctx, query, args...).Scan(&pair[0], &pair[1]); err != nil {
+			return nil, err
+		}
+		counts[period] = pair
+	}
+	return counts, nil
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+func compareHandler(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	if err := acknowledgeInteraction(s, i); err != nil {
+		return
+	}
+
+	options := optionsByName(i.ApplicationCommandData().Options)
+	first := options["user1"].UserValue(s)
+	second := options["user2"].UserValue(s)
+	if first.ID == second.ID {
+		if err := sendResponse(s, i, "Comparing someone with themselves? Skill issue"); err != nil {
+			log.Printf("Error sending detailed response: %v", err)
+		}
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	filter := scopeFilter(i)
+	periods, err := comparePeriods(ctx, filter, first.ID, second.ID, time.Now().In(getGuildSettings(i.GuildID).location()))
+	var words []wordComparison
+	if err == nil {
+		words, err = compareWords(ctx, filter, first.ID, second.ID)
+	}
+	if err != nil {
+		log.Printf("Error executing compare query: %v", err)
+		if err := sendResponse(s, i, "Failed to compare users"); err != nil {
+			log.Printf("Error sending detailed response: %v", err)
+		}
+		return
+	}
+
+	labels := map[string]string{
+		periodToday: "Today",
+		periodWeek:  "This week",
+		periodMonth: "This month",
+		periodYear:  "This year",
+		periodAll:   "All time",
+	}
+	var trend []string
+	for _, period := range []string{periodToday, periodWeek, periodMonth, periodYear, periodAll} {
+		pair := periods[period]
+		marker := "🤝"
+		if pair[0] > pair[1] {
+			marker = "⬅️"
+		} else if pair[1] > pair[0] {
+			marker = "➡️"
+		}
+		trend = append(trend, fmt.Sprintf("%s: **%d** %s **%d**", labels[period], pair[0], marker, pair[1]))
+	}
+
+	var diffs []string
+	for _, c := range words {
+		if len(diffs) == 10 {
+			break
+		}
+		diffs = append(diffs, fmt.Sprintf("%s: **%d** vs **%d**", c.Word, c.First, c.Second))
+	}
+	if len(diffs) == 0 {
+		diffs = append(diffs, "Neither of them has said anything unholy yet.")
+	}
+
+	total := periods[periodAll]
+	winner := "It's a tie! Equally unholy."
+	switch {
+	case total[0] > total[1]:
+		winner = fmt.Sprintf("🏆 **%s** is dirtier by %d entries", first.Username, total[0]-total[1])
+	case total[1] > total[0]:
+		winner = fmt.Sprintf("🏆 **%s** is dirtier by %d entries", second.Username, total[1]-total[0])
+	}
+
+	embed := &discordgo.MessageEmbed{
+		Title:       fmt.Sprintf("%s vs %s", first.Username, second.Username),
+		Description: winner,
+		Color:       0xff0000,
+		Fields: []*discordgo.MessageEmbedField{
+			{Name: "Totals", Value: fmt.Sprintf("%s: **%d**\n%s: **%d**", first.Username, total[0], second.Username, total[1]), Inline: true},
+			{Name: "Trend", Value: strings.Join(trend, "\n"), Inline: true},
+			{Name: "Biggest word differences", Value: strings.Join(diffs, "\n")},
+		},
+		Timestamp: time.Now().Format(time.RFC3339),
+	}
+
+	if err := sendEmbedResponse(s, i, embed); err != nil {
+		log.Printf("Error sending detailed response: %v", err)
+	}
+}
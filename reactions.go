@@ -0,0 +1,45 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// Stored messages keep a running count of their reactions, so the most
+// reacted ones can be found without asking Discord for every message.
+
+// reactionCount adds up all reactions on a message, for messages stored
+// while backtracking that already have some.
+func reactionCount(msg *discordgo.Message) int {
+	count := 0
+	for _, reaction := range msg.Reactions {
+		count += reaction.Count
+	}
+	return count
+}
+
+func updateReactions(messageID, update string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// Only flagged messages are stored, so most reactions match no row.
+	query := `UPDATE messages SET reactions = ` + update + ` WHERE messageID = $1;`
+	if _, err := dbpool.Exec(ctx, query, messageID); err != nil {
+		log.Printf("Error updating reactions: %v", err)
+	}
+}
+
+func messageReactionAdd(s *discordgo.Session, r *discordgo.MessageReactionAdd) {
+	updateReactions(r.MessageID, "reactions + 1")
+}
+
+func messageReactionRemove(s *discordgo.Session, r *discordgo.MessageReactionRemove) {
+	updateReactions(r.MessageID, "GREATEST(reactions - 1, 0)")
+}
+
+func messageReactionRemoveAll(s *discordgo.Session, r *discordgo.MessageReactionRemoveAll) {
+	updateReactions(r.MessageID, "0")
+}
@@ -0,0 +1,434 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// roleRewardsJob is the name of the role reconciliation in the schedules
+// table. It is scheduled while a guild has any role rewards.
+const (
+	roleRewardsJob  = "rolerewards"
+	roleRewardsCron = "0 * * * *"
+)
+
+// Kinds of role reward.
+const (
+	rewardWeeklyTop = "weekly_top" // the #1 of last week's scoreboard
+	rewardThreshold = "threshold"  // everyone with at least Threshold entries
+)
+
+type roleReward struct {
+	RoleID    string
+	Kind      string
+	Threshold int
+}
+
+func (r roleReward) describe() string {
+	if r.Kind == rewardThreshold {
+		return fmt.Sprintf("%d entries or more", r.Threshold)
+	}
+	return "#1 of last week"
+}
+
+func fetchRoleRewards(ctx context.Context, guildID string) ([]roleReward, error) {
+	rows, err := dbpool.Query(ctx, `SELECT roleid, kind, threshold FROM role_rewards WHERE serverid = $1 ORDER BY kind, threshold, roleid;`, guildID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var rewards []roleReward
+	for rows.Next() {
+		var r roleReward
+		if err := rows.Scan(&r.RoleID, &r.Kind, &r.Threshold); err != nil {
+			return nil, err
+		}
+		rewards = append(rewards, r)
+	}
+	return rewards, rows.Err()
+}
+
+// rewardWinners returns the users who should have the reward's role now.
+func rewardWinners(ctx context.Context, guildID string, r roleReward, now time.Time) (map[string]bool, error) {
+	winners := map[string]bool{}
+	if r.Kind == rewardWeeklyTop {
+		week := periodStart(periodWeek, now.In(getGuildSettings(guildID).location())).AddDate(0, 0, -7)
+		scores, err := fetchScores(ctx, messageFilter{ServerID: guildID, From: week, To: week.AddDate(0, 0, 7)})
+		if err != nil {
+			return nil, err
+		}
+		for _, score := range scores {
+			if score.Rank > 1 {
+				break
+			}
+			winners[score.UserID] = true
+		}
+		return winners, nil
+	}
+
+	// Thresholds below 1 are refused by /rolereward add; never act on one.
+	if r.Threshold < 1 {
+		return winners, nil
+	}
+	rows, err := dbpool.Query(ctx, `SELECT userID FROM messages WHERE serverID = $1 GROUP BY userID HAVING COUNT(*) >= $2;`, guildID, r.Threshold)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var userID string
+		if err := rows.Scan(&userID); err != nil {
+			return nil, err
+		}
+		winners[userID] = true
+	}
+	return winners, rows.Err()
+}
+
+// fetchRoleHolders returns the users the bot gave a role to. Only those are
+// ever taken away again, so roles handed out by hand are left alone.
+func fetchRoleHolders(ctx context.Context, guildID, roleID string) (map[string]bool, error) {
+	rows, err := dbpool.Query(ctx, `SELECT userid FROM role_reward_holders WHERE serverid = $1 AND roleid = $2;`, guildID, roleID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	holders := map[string]bool{}
+	for rows.Next() {
+		var userID string
+		if err := rows.Scan(&userID); err != nil {
+			return nil, err
+		}
+		holders[userID] = true
+	}
+	return holders, rows.Err()
+}
+
+// discordErrorCode is the JSON error code of a failed API call, or 0.
+func discordErrorCode(err error) int {
+	var restErr *discordgo.RESTError
+	if errors.As(err, &restErr) && restErr.Message != nil {
+		return restErr.Message.Code
+	}
+	return 0
+}
+
+// guildRoles holds what roleProblem needs to know about a guild: its roles
+// and the bot's own member with the roles it has.
+type guildRoles struct {
+	roles []*discordgo.Role
+	me    *discordgo.Member
+}
+
+func fetchGuildRoles(s *discordgo.Session, guildID string) (guildRoles, error) {
+	roles, err := s.GuildRoles(guildID)
+	if err != nil {
+		return guildRoles{}, err
+	}
+	me, err := s.GuildMember(guildID, s.State.User.ID)
+	if err != nil {
+		return guildRoles{}, err
+	}
+	return guildRoles{roles: roles, me: me}, nil
+}
+
+// roleProblem explains in words why the bot cannot hand out a role, or
+// returns "" when it can.
+func roleProblem(g guildRoles, guildID, roleID string) string {
+	var role *discordgo.Role
+	highest := 0
+	var permissions int64
+	mine := map[string]bool{guildID: true} // everyone has @everyone
+	for _, id := range g.me.Roles {
+		mine[id] = true
+	}
+	for _, r := range g.roles {
+		if r.ID == roleID {
+			role = r
+		}
+		if mine[r.ID] {
+			permissions |= r.Permissions
+			if r.ID != guildID && r.Position > highest {
+				highest = r.Position
+			}
+		}
+	}
+
+	switch {
+	case role == nil:
+		return "the role no longer exists, remove the reward with /rolereward remove"
+	case role.Managed:
+		return fmt.Sprintf("**%s** is managed by an integration and cannot be handed out", role.Name)
+	case permissions&(discordgo.PermissionAdministrator|discordgo.PermissionManageRoles) == 0:
+		return "the bot is missing the Manage Roles permission"
+	case role.Position >= highest:
+		return fmt.Sprintf("**%s** is not below the bot's highest role, drag the bot's role above it in Server Settings → Roles", role.Name)
+	}
+	return ""
+}
+
+// reconcileRoleRewards hands out and takes back the reward roles of a guild
+// so they match the current standings. Problems the admin has to fix, such as
+// missing permissions, and rewards that fail are collected into the returned
+// error while the other rewards still go ahead.
+func reconcileRoleRewards(s *discordgo.Session, guildID string, now time.Time) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+
+	rewards, err := fetchRoleRewards(ctx, guildID)
+	if err != nil {
+		return err
+	}
+	if len(rewards) == 0 {
+		return nil
+	}
+	g, err := fetchGuildRoles(s, guildID)
+	if err != nil {
+		return fmt.Errorf("checking roles: %v", err)
+	}
+
+	var problems []string
+	for _, reward := range rewards {
+		if problem := roleProblem(g, guildID, reward.RoleID); problem != "" {
+			problems = append(problems, fmt.Sprintf("<@&%s>: %s", reward.RoleID, problem))
+			continue
+		}
+
+		winners, err := rewardWinners(ctx, guildID, reward, now)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("<@&%s>: finding who earned it failed: %v", reward.RoleID, err))
+			continue
+		}
+		holders, err := fetchRoleHolders(ctx, guildID, reward.RoleID)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("<@&%s>: finding who has it failed: %v", reward.RoleID, err))
+			continue
+		}
+
+		for userID := range winners {
+			if holders[userID] {
+				continue
+			}
+			err := s.GuildMemberRoleAdd(guildID, userID, reward.RoleID)
+			switch discordErrorCode(err) {
+			case 0:
+				if err != nil {
+					log.Printf("Error adding role %s to %s: %v", reward.RoleID, userID, err)
+					continue
+				}
+			case discordgo.ErrCodeUnknownMember:
+				continue // left the server
+			case discordgo.ErrCodeMissingPermissions:
+				problems = append(problems, fmt.Sprintf("<@&%s>: Discord refused to hand out the role, check the bot's permissions and role order", reward.RoleID))
+			default:
+				log.Printf("Error adding role %s to %s: %v", reward.RoleID, userID, err)
+				continue
+			}
+			if err != nil {
+				break
+			}
+			if _, err := dbpool.Exec(ctx, `INSERT INTO role_reward_holders (serverid, roleid, userid) VALUES ($1, $2, $3) ON CONFLICT DO NOTHING;`, guildID, reward.RoleID, userID); err != nil {
+				log.Printf("Error recording role holder: %v", err)
+			}
+		}
+
+		for userID := range holders {
+			if winners[userID] {
+				continue
+			}
+			if problem := takeRewardRole(ctx, s, guildID, reward.RoleID, userID); problem != "" {
+				problems = append(problems, problem)
+				break
+			}
+		}
+	}
+
+	if len(problems) > 0 {
+		return errors.New(strings.Join(problems, "\n"))
+	}
+	return nil
+}
+
+// takeRewardRole removes a reward role the bot gave out. It returns a problem
+// for the admin when Discord refuses.
+func takeRewardRole(ctx context.Context, s *discordgo.Session, guildID, roleID, userID string) string {
+	err := s.GuildMemberRoleRemove(guildID, userID, roleID)
+	switch discordErrorCode(err) {
+	case 0:
+		if err != nil {
+			log.Printf("Error removing role %s from %s: %v", roleID, userID, err)
+			return ""
+		}
+	case discordgo.ErrCodeUnknownMember, discordgo.ErrCodeUnknownRole:
+	case discordgo.ErrCodeMissingPermissions:
+		return fmt.Sprintf("<@&%s>: Discord refused to take back the role, check the bot's permissions and role order", roleID)
+	default:
+		log.Printf("Error removing role %s from %s: %v", roleID, userID, err)
+		return ""
+	}
+
+	if _, err := dbpool.Exec(ctx, `DELETE FROM role_reward_holders WHERE serverid = $1 AND roleid = $2 AND userid = $3;`, guildID, roleID, userID); err != nil {
+		log.Printf("Error removing role holder: %v", err)
+	}
+	return ""
+}
+
+// postRoleRewards is the scheduled job reconciling the reward roles. The admin
+// is told about problems by DM, but only when they change, not every hour.
+func postRoleRewards(s *discordgo.Session, sched schedule, at time.Time) error {
+	err := reconcileRoleRewards(s, sched.ServerID, at)
+	if err != nil && err.Error() != sched.LastError {
+		sendAdminDM(fmt.Sprintf("Role rewards in server %s need attention:\n%s", sched.ServerID, err))
+	}
+	return err
+}
+
+func roleRewardHandler(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	if err := acknowledgeInteraction(s, i); err != nil {
+		return
+	}
+
+	response := "Skill issue"
+	adminUserID := os.Getenv("ADMIN_ID")
+
+	if i.Member.User.ID != adminUserID {
+		if err := sendResponse(s, i, response); err != nil {
+			log.Printf("Error sending detailed response: %v", err)
+		}
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	sub := i.ApplicationCommandData().Options[0]
+	options := optionsByName(sub.Options)
+	switch sub.Name {
+	case "add":
+		reward := roleReward{RoleID: options["role"].Value.(string), Kind: options["kind"].StringValue()}
+		if reward.Kind == rewardThreshold {
+			option, ok := options["threshold"]
+			if !ok || option.IntValue() < 1 {
+				response = "A threshold reward needs the _threshold_ option, the number of entries to reach, of 1 or more."
+				break
+			}
+			reward.Threshold = int(option.IntValue())
+		}
+
+		_, err := dbpool.Exec(ctx, `INSERT INTO role_rewards (serverid, roleid, kind, threshold) VALUES ($1, $2, $3, $4)
+			ON CONFLICT (serverid, roleid) DO UPDATE SET kind = EXCLUDED.kind, threshold = EXCLUDED.threshold;`,
+			i.GuildID, reward.RoleID, reward.Kind, reward.Threshold)
+		if err == nil {
+			_, err = saveSchedule(ctx, i.GuildID, roleRewardsJob, roleRewardsCron, "")
+		}
+		if err != nil {
+			log.Printf("Error saving role reward: %v", err)
+			response = fmt.Sprintf("Failed to save the role reward: %v", err)
+			break
+		}
+		recordAudit(i.GuildID, i.Member.User.ID, "rolereward add", fmt.Sprintf("role=%s kind=%s threshold=%d", reward.RoleID, reward.Kind, reward.Threshold))
+
+		response = fmt.Sprintf("<@&%s> now goes to %s.", reward.RoleID, reward.describe())
+		if err := reconcileRoleRewards(s, i.GuildID, time.Now()); err != nil {
+			response += "\nBut handing it out failed:\n" + err.Error()
+		}
+
+	case "remove":
+		roleID := options["role"].Value.(string)
+		tag, err := dbpool.Exec(ctx, `DELETE FROM role_rewards WHERE serverid = $1 AND roleid = $2;`, i.GuildID, roleID)
+		if err != nil {
+			log.Printf("Error removing role reward: %v", err)
+			response = "Failed to remove the role reward"
+			break
+		}
+		if tag.RowsAffected() == 0 {
+			response = fmt.Sprintf("<@&%s> is not a role reward.", roleID)
+			break
+		}
+		recordAudit(i.GuildID, i.Member.User.ID, "rolereward remove", "role="+roleID)
+
+		// Take the role back from everyone the bot gave it to. The holders
+		// it cannot be taken from keep their rows, so adding the reward again
+		// later takes it back from them too.
+		holders, err := fetchRoleHolders(ctx, i.GuildID, roleID)
+		var problem string
+		for userID := range holders {
+			if problem = takeRewardRole(ctx, s, i.GuildID, roleID, userID); problem != "" {
+				break
+			}
+		}
+		if _, err := dbpool.Exec(ctx, `DELETE FROM schedules WHERE serverid = $1 AND job = $2
+			AND NOT EXISTS (SELECT 1 FROM role_rewards WHERE serverid = $1);`, i.GuildID, roleRewardsJob); err != nil {
+			log.Printf("Error deleting role rewards schedule: %v", err)
+		}
+
+		if err != nil {
+			log.Printf("Error fetching role holders: %v", err)
+			response = fmt.Sprintf("<@&%s> is no longer a role reward, but finding who the bot gave it to failed, so nobody lost it: %v", roleID, err)
+			break
+		}
+		left, err := fetchRoleHolders(ctx, i.GuildID, roleID)
+		if err != nil {
+			log.Printf("Error fetching role holders: %v", err)
+			response = fmt.Sprintf("<@&%s> is no longer a role reward, but checking who still has it failed: %v", roleID, err)
+			break
+		}
+		response = fmt.Sprintf("<@&%s> is no longer a role reward and was taken back from %d users.", roleID, len(holders)-len(left))
+		if len(left) > 0 {
+			mentions := make([]string, 0, len(left))
+			for userID := range left {
+				mentions = append(mentions, "<@"+userID+">")
+			}
+			sort.Strings(mentions)
+			response = fmt.Sprintf("<@&%s> is no longer a role reward and was taken back from %d users, but these still have it: %s", roleID, len(holders)-len(left), strings.Join(mentions, ", "))
+			if problem != "" {
+				response += "\n" + problem
+			}
+		}
+
+	case "list":
+		rewards, err := fetchRoleRewards(ctx, i.GuildID)
+		if err != nil {
+			log.Printf("Error fetching role rewards: %v", err)
+			response = "Failed to fetch role rewards"
+			break
+		}
+		if len(rewards) == 0 {
+			response = "This server has no role rewards."
+			break
+		}
+		g, err := fetchGuildRoles(s, i.GuildID)
+		if err != nil {
+			log.Printf("Error fetching guild roles: %v", err)
+		}
+		lines := []string{"Role rewards, updated every hour:"}
+		for _, reward := range rewards {
+			line := fmt.Sprintf("> <@&%s>: %s", reward.RoleID, reward.describe())
+			if err == nil {
+				if problem := roleProblem(g, i.GuildID, reward.RoleID); problem != "" {
+					line += "\n> ⚠️ " + problem
+				}
+			}
+			lines = append(lines, line)
+		}
+		response = strings.Join(lines, "\n")
+	}
+
+	_, err := s.FollowupMessageCreate(i.Interaction, true, &discordgo.WebhookParams{
+		Content:         truncate(response, 2000),
+		AllowedMentions: &discordgo.MessageAllowedMentions{},
+	})
+	if err != nil {
+		log.Printf("Error sending detailed response: %v", err)
+	}
+}
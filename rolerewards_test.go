@@ -0,0 +1,41 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+func TestRoleProblem(t *testing.T) {
+	const guildID = "1"
+	roles := []*discordgo.Role{
+		{ID: guildID, Name: "@everyone", Position: 0},
+		{ID: "2", Name: "Reward", Position: 1},
+		{ID: "3", Name: "Bot", Position: 2, Permissions: discordgo.PermissionManageRoles},
+		{ID: "4", Name: "Booster", Position: 3, Managed: true},
+		{ID: "5", Name: "Mods", Position: 4},
+	}
+	bot := guildRoles{roles: roles, me: &discordgo.Member{Roles: []string{"3"}}}
+	plain := guildRoles{roles: roles, me: &discordgo.Member{Roles: []string{"5"}}}
+
+	tests := []struct {
+		name   string
+		g      guildRoles
+		roleID string
+		want   string
+	}{
+		{"role below the bot", bot, "2", ""},
+		{"deleted role", bot, "9", "no longer exists"},
+		{"managed role", bot, "4", "managed by an integration"},
+		{"no permission", plain, "2", "Manage Roles"},
+		{"role above the bot", bot, "5", "not below the bot's highest role"},
+		{"the bot's own role", bot, "3", "not below the bot's highest role"},
+	}
+	for _, tt := range tests {
+		got := roleProblem(tt.g, guildID, tt.roleID)
+		if tt.want == "" && got != "" || !strings.Contains(got, tt.want) {
+			t.Errorf("%s: roleProblem = %q, want it to mention %q", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,314 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/jackc/pgx/v4"
+)
+
+// scheduleInterval is how often the scheduler looks for due schedules.
+const scheduleInterval = 30 * time.Second
+
+// schedule is a row of the schedules table: a job run for a guild whenever its
+// cron expression matches, in the guild's time zone.
+type schedule struct {
+	ID        int64
+	ServerID  string
+	Job       string
+	Cron      string
+	ChannelID string
+	Paused    bool
+	LastRun   time.Time // zero if it never ran
+	NextRun   time.Time
+	LastError string
+}
+
+// scheduledJob runs a schedule. at is when the run was due, which lies in the
+// past when a run missed while the bot was down is caught up.
+type scheduledJob func(s *discordgo.Session, sched schedule, at time.Time) error
+
+// scheduledJobs holds every job a schedule can name.
+var scheduledJobs = map[string]scheduledJob{
+	digestJob:      postDigest,
+	onThisDayJob:   postOnThisDay,
+	streaksJob:     postBrokenStreaks,
+	roleRewardsJob: postRoleRewards,
+	badgesJob:      postWeeklyBadges,
+}
+
+// nextRun is the first time after t the cron expression matches in the
+// guild's time zone.
+func nextRun(cronExpr, guildID string, t time.Time) (time.Time, error) {
+	spec, err := parseCron(cronExpr)
+	if err != nil {
+		return time.Time{}, err
+	}
+	next := spec.next(t.In(getGuildSettings(guildID).location()))
+	if next.IsZero() {
+		return time.Time{}, fmt.Errorf("cron expression %q never matches", cronExpr)
+	}
+	return next, nil
+}
+
+// saveSchedule creates the guild's schedule for job, or changes its cron
+// expression and channel if it already has one. It returns the next run.
+func saveSchedule(ctx context.Context, guildID, job, cronExpr, channelID string) (time.Time, error) {
+	if _, ok := scheduledJobs[job]; !ok {
+		return time.Time{}, fmt.Errorf("unknown job %q", job)
+	}
+	next, err := nextRun(cronExpr, guildID, time.Now())
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	query := `INSERT INTO schedules (serverid, job, cron, channelid, next_run) VALUES ($1, $2, $3, NULLIF($4, ''), $5)
+		ON CONFLICT (serverid, job) DO UPDATE SET cron = EXCLUDED.cron, channelid = EXCLUDED.channelid, next_run = EXCLUDED.next_run;`
+	_, err = dbpool.Exec(ctx, query, guildID, job, cronExpr, channelID, next)
+	return next, err
+}
+
+// ensureSchedule creates the guild's schedule for job unless it already has
+// one, leaving a paused or changed schedule alone.
+func ensureSchedule(ctx context.Context, guildID, job, cronExpr string) error {
+	next, err := nextRun(cronExpr, guildID, time.Now())
+	if err != nil {
+		return err
+	}
+	_, err = dbpool.Exec(ctx, `INSERT INTO schedules (serverid, job, cron, next_run) VALUES ($1, $2, $3, $4)
+		ON CONFLICT (serverid, job) DO NOTHING;`, guildID, job, cronExpr, next)
+	return err
+}
+
+// fetchSchedules loads the schedules matching the condition after WHERE.
+func fetchSchedules(ctx context.Context, condition string, args ...interface{}) ([]schedule, error) {
+	query := `SELECT id, serverid, job, cron, COALESCE(channelid, ''), paused, COALESCE(last_run, 'epoch'), next_run, COALESCE(last_error, '')
+		FROM schedules WHERE ` + condition + ` ORDER BY next_run, id;`
+	rows, err := dbpool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var schedules []schedule
+	for rows.Next() {
+		var sched schedule
+		if err := rows.Scan(&sched.ID, &sched.ServerID, &sched.Job, &sched.Cron, &sched.ChannelID, &sched.Paused, &sched.LastRun, &sched.NextRun, &sched.LastError); err != nil {
+			return nil, err
+		}
+		if sched.LastRun.Unix() == 0 {
+			sched.LastRun = time.Time{}
+		}
+		schedules = append(schedules, sched)
+	}
+	return schedules, rows.Err()
+}
+
+// rescheduleGuild works out the next runs of a guild's schedules again, for
+// when its time zone changes.
+func rescheduleGuild(ctx context.Context, guildID string) error {
+	schedules, err := fetchSchedules(ctx, "serverid = $1", guildID)
+	if err != nil {
+		return err
+	}
+	for _, sched := range schedules {
+		next, err := nextRun(sched.Cron, guildID, time.Now())
+		if err != nil {
+			return err
+		}
+		if _, err := dbpool.Exec(ctx, `UPDATE schedules SET next_run = $2 WHERE id = $1;`, sched.ID, next); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// runScheduler runs due schedules until ctx is cancelled. A schedule that
+// became due while the bot was down runs once when it is back, however many
+// runs were missed, and then carries on from the current time.
+func runScheduler(ctx context.Context, s *discordgo.Session) {
+	ticker := time.NewTicker(scheduleInterval)
+	defer ticker.Stop()
+
+	for {
+		runDueSchedules(ctx, s, time.Now())
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func runDueSchedules(ctx context.Context, s *discordgo.Session, now time.Time) {
+	queryCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	due, err := fetchSchedules(queryCtx, "NOT paused AND next_run <= $1", now)
+	cancel()
+	if err != nil {
+		log.Printf("Error fetching due schedules: %v", err)
+		return
+	}
+
+	for _, sched := range due {
+		if ctx.Err() != nil {
+			return
+		}
+		if err := claimSchedule(ctx, sched, now); err != nil {
+			if err != pgx.ErrNoRows {
+				log.Printf("Error claiming schedule %d: %v", sched.ID, err)
+			}
+			continue
+		}
+		if now.Sub(sched.NextRun) > 2*scheduleInterval {
+			log.Printf("Catching up missed run of %s for %s due %v", sched.Job, sched.ServerID, sched.NextRun)
+		}
+
+		err := runJob(s, sched)
+		if err != nil {
+			log.Printf("Error running %s for %s: %v", sched.Job, sched.ServerID, err)
+		}
+		errText := ""
+		if err != nil {
+			errText = err.Error()
+		}
+		queryCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		if _, err := dbpool.Exec(queryCtx, `UPDATE schedules SET last_error = NULLIF($2, '') WHERE id = $1;`, sched.ID, errText); err != nil {
+			log.Printf("Error recording result of schedule %d: %v", sched.ID, err)
+		}
+		cancel()
+	}
+}
+
+// claimSchedule moves a due schedule on to its next run before it runs, so a
+// second bot instance, or the next tick, cannot run it again. Schedules whose
+// expression no longer parses are paused. pgx.ErrNoRows means someone else
+// claimed it first.
+func claimSchedule(ctx context.Context, sched schedule, now time.Time) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	next, err := nextRun(sched.Cron, sched.ServerID, now)
+	if err != nil {
+		dbpool.Exec(ctx, `UPDATE schedules SET paused = true, last_error = $2 WHERE id = $1;`, sched.ID, err.Error())
+		return err
+	}
+	tag, err := dbpool.Exec(ctx, `UPDATE schedules SET next_run = $2, last_run = $3 WHERE id = $1 AND next_run = $4 AND NOT paused;`,
+		sched.ID, next, sched.NextRun, sched.NextRun)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+	return nil
+}
+
+// runJob runs the job of a schedule, turning a panic into an error so one
+// broken job cannot take the bot down.
+func runJob(s *discordgo.Session, sched schedule) (err error) {
+	job, ok := scheduledJobs[sched.Job]
+	if !ok {
+		return fmt.Errorf("unknown job %q", sched.Job)
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("job panicked: %v", r)
+		}
+	}()
+	return job(s, sched, sched.NextRun)
+}
+
+func scheduleHandler(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	if err := acknowledgeInteraction(s, i); err != nil {
+		return
+	}
+
+	response := "Skill issue"
+	adminUserID := os.Getenv("ADMIN_ID")
+
+	if i.Member.User.ID != adminUserID {
+		if err := sendResponse(s, i, response); err != nil {
+			log.Printf("Error sending detailed response: %v", err)
+		}
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	sub := i.ApplicationCommandData().Options[0]
+	switch sub.Name {
+	case "list":
+		schedules, err := fetchSchedules(ctx, "serverid = $1", i.GuildID)
+		if err != nil {
+			log.Printf("Error fetching schedules: %v", err)
+			response = "Failed to fetch schedules"
+			break
+		}
+		if len(schedules) == 0 {
+			response = "Nothing is scheduled for this server."
+			break
+		}
+
+		lines := []string{fmt.Sprintf("Schedules for this server (%s time):", getGuildSettings(i.GuildID).Timezone)}
+		for _, sched := range schedules {
+			line := fmt.Sprintf("> **#%d %s** `%s`", sched.ID, sched.Job, sched.Cron)
+			if sched.ChannelID != "" {
+				line += fmt.Sprintf(" in <#%s>", sched.ChannelID)
+			}
+			if sched.Paused {
+				line += ", **paused**"
+			} else {
+				line += ", next " + discordTimestamp(sched.NextRun, timestampRelative)
+			}
+			if !sched.LastRun.IsZero() {
+				line += ", last " + discordTimestamp(sched.LastRun, timestampShortDayTime)
+			}
+			if sched.LastError != "" {
+				line += fmt.Sprintf("\n> ⚠️ %s", truncate(sched.LastError, 200))
+			}
+			lines = append(lines, line)
+		}
+		response = strings.Join(lines, "\n")
+
+	case "pause", "resume":
+		id := sub.Options[0].IntValue()
+		paused := sub.Name == "pause"
+
+		schedules, err := fetchSchedules(ctx, "id = $1 AND serverid = $2", id, i.GuildID)
+		if err != nil || len(schedules) == 0 {
+			response = fmt.Sprintf("There is no schedule #%d on this server.", id)
+			break
+		}
+		sched := schedules[0]
+
+		// Resuming starts from now instead of catching up on the paused runs.
+		next := sched.NextRun
+		if !paused {
+			if next, err = nextRun(sched.Cron, sched.ServerID, time.Now()); err != nil {
+				response = fmt.Sprintf("Cannot resume #%d: %v", id, err)
+				break
+			}
+		}
+		if _, err := dbpool.Exec(ctx, `UPDATE schedules SET paused = $2, next_run = $3 WHERE id = $1;`, id, paused, next); err != nil {
+			log.Printf("Error updating schedule: %v", err)
+			response = "Failed to update the schedule"
+			break
+		}
+		recordAudit(i.GuildID, i.Member.User.ID, "schedule "+sub.Name, fmt.Sprintf("#%d %s", id, sched.Job))
+
+		response = fmt.Sprintf("Paused **#%d %s**.", id, sched.Job)
+		if !paused {
+			response = fmt.Sprintf("Resumed **#%d %s**, next run %s.", id, sched.Job, discordTimestamp(next, timestampShortDayTime))
+		}
+	}
+
+	if err := sendResponse(s, i, response); err != nil {
+		log.Printf("Error sending detailed response: %v", err)
+	}
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// Scoreboard pages are laid out as scoreboardColumns inline fields of
+// scoreboardRows lines each, which keeps every page well inside Discord's
+// limits of 25 fields and 6000 characters per embed.
+const (
+	scoreboardColumns  = 3
+	scoreboardRows     = 10
+	scoreboardPageSize = scoreboardColumns * scoreboardRows
+)
+
+// rankedScore is a leaderboard entry with its competition rank, so users with
+// the same count share a position.
+type rankedScore struct {
+	userScore
+	Rank int
+}
+
+// fetchScores ranks users by how many messages match filter.
+func fetchScores(ctx context.Context, filter messageFilter) ([]rankedScore, error) {
+	where, args := filter.where("", nil)
+	query := `SELECT UserID, COUNT(*) AS message_count FROM messages ` + where + ` GROUP BY UserID ORDER BY message_count DESC, UserID;`
+	rows, err := dbpool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var scores []rankedScore
+	for rows.Next() {
+		var us rankedScore
+		if err := rows.Scan(&us.UserID, &us.MessageCount); err != nil {
+			log.Printf("Error scanning scoreboard row: %v", err)
+			continue
+		}
+		us.Rank = len(scores) + 1
+		if len(scores) > 0 && scores[len(scores)-1].MessageCount == us.MessageCount {
+			us.Rank = scores[len(scores)-1].Rank
+		}
+		scores = append(scores, us)
+	}
+	return scores, rows.Err()
+}
+
+// usernameCache looks up and remembers usernames, so turning pages back and
+// forth does not hit the API again for the same users.
+type usernameCache map[string]string
+
+func (c usernameCache) get(s *discordgo.Session, userID string) string {
+	if name, ok := c[userID]; ok {
+		return name
+	}
+	name := "Unknown User"
+	user, err := s.User(userID)
+	if err != nil {
+		log.Printf("Error fetching user %s: %v", userID, err)
+	} else {
+		name = user.Username
+	}
+	c[userID] = name
+	return name
+}
+
+func scoreboardLine(s *discordgo.Session, names usernameCache, score rankedScore) string {
+	icon := fmt.Sprintf("`#%d`", score.Rank)
+	switch score.Rank {
+	case 1:
+		icon = "🥇"
+	case 2:
+		icon = "🥈"
+	case 3:
+		icon = "🥉"
+	}
+	return fmt.Sprintf("%s %s — **%d**", icon, truncate(names.get(s, score.UserID), 20), score.MessageCount)
+}
+
+// scoreboardRenderer returns a pageRenderer for the leaderboard of filter.
+// window describes the filter for the embed and invokerID is the user whose
+// position is shown on every page.
+func scoreboardRenderer(s *discordgo.Session, filter messageFilter, window, invokerID string) pageRenderer {
+	names := usernameCache{}
+
+	return func(page int) (*discordgo.MessageEmbed, int, error) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		scores, err := fetchScores(ctx, filter)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		embed := &discordgo.MessageEmbed{
+			Title:     "Scoreboard",
+			Color:     0x00ff00, // Green color
+			Timestamp: time.Now().Format(time.RFC3339),
+		}
+
+		if len(scores) == 0 {
+			embed.Description = fmt.Sprintf("No words has been recorded for %s yet! be the first :D", window)
+			return embed, 1, nil
+		}
+
+		totalPages := (len(scores) + scoreboardPageSize - 1) / scoreboardPageSize
+		if page >= totalPages {
+			page = totalPages - 1
+		}
+
+		position := "You are not on this scoreboard yet."
+		for _, score := range scores {
+			if score.UserID == invokerID {
+				position = fmt.Sprintf("Your position: **#%d** of %d with **%d** entries", score.Rank, len(scores), score.MessageCount)
+				break
+			}
+		}
+		embed.Description = fmt.Sprintf("Top message counts for %s:\n%s", window, position)
+
+		start := page * scoreboardPageSize
+		end := start + scoreboardPageSize
+		if end > len(scores) {
+			end = len(scores)
+		}
+		for column := start; column < end; column += scoreboardRows {
+			columnEnd := column + scoreboardRows
+			if columnEnd > end {
+				columnEnd = end
+			}
+			var lines []string
+			for _, score := range scores[column:columnEnd] {
+				lines = append(lines, scoreboardLine(s, names, score))
+			}
+			embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{
+				Name:   fmt.Sprintf("#%d–%d", column+1, columnEnd),
+				Value:  strings.Join(lines, "\n"),
+				Inline: true,
+			})
+		}
+
+		embed.Footer = pageFooter(page, totalPages, len(scores), "users")
+		return embed, totalPages, nil
+	}
+}
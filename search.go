@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// searchPageSize is how many results one page of /search shows.
+const searchPageSize = 8
+
+// searchConfig is the text search configuration of the messages.search
+// column. The simple configuration does no stemming, so both English and
+// Norwegian messages are indexed word for word.
+//
+// Searching is done by Postgres alone. The bot keeps no messages in memory,
+// only the word matcher, so there is no in-memory store to search as well.
+const searchConfig = "simple"
+
+// searchRenderer returns a pageRenderer for the messages matching a full-text
+// query, best matches first. The query uses web search syntax: "quoted
+// phrases", or and -excluded words.
+func searchRenderer(s *discordgo.Session, text string, filter messageFilter) pageRenderer {
+	names := usernameCache{}
+
+	return func(page int) (*discordgo.MessageEmbed, int, error) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		where, args := filter.where("m", nil)
+		args = append(args, text)
+		tsquery := fmt.Sprintf("websearch_to_tsquery('%s', $%d)", searchConfig, len(args))
+		if where == "" {
+			where = "WHERE m.search @@ " + tsquery
+		} else {
+			where += " AND m.search @@ " + tsquery
+		}
+
+		var total int
+		if err := dbpool.QueryRow(ctx, `SELECT COUNT(*) FROM messages m `+where, args...).Scan(&total); err != nil {
+			return nil, 0, fmt.Errorf("counting results: %v", err)
+		}
+		totalPages := (total + searchPageSize - 1) / searchPageSize
+		if totalPages == 0 {
+			totalPages = 1
+		}
+		if page >= totalPages {
+			page = totalPages - 1
+		}
+
+		query := fmt.Sprintf(`SELECT m.serverID, COALESCE(m.channelID, ''), COALESCE(m.messageID, ''), m.userID, m.timestamp,
+			ts_headline('%[1]s', m.message, %[2]s, 'StartSel=**, StopSel=**, MaxWords=30, MinWords=10')
+			FROM messages m %[3]s
+			ORDER BY ts_rank_cd(m.search, %[2]s) DESC, m.timestamp DESC LIMIT %[4]d OFFSET %[5]d`,
+			searchConfig, tsquery, where, searchPageSize, page*searchPageSize)
+		rows, err := dbpool.Query(ctx, query, args...)
+		if err != nil {
+			return nil, 0, fmt.Errorf("searching messages: %v", err)
+		}
+		defer rows.Close()
+
+		var results []string
+		for rows.Next() {
+			var o offense
+			var userID string
+			if err := rows.Scan(&o.ServerID, &o.ChannelID, &o.MessageID, &userID, &o.Timestamp, &o.Message); err != nil {
+				log.Printf("Error scanning row: %v", err)
+				continue
+			}
+
+			headline := strings.ReplaceAll(truncate(o.Message, 300), "\n", " ")
+			results = append(results, fmt.Sprintf("**%s** • %s\n> %s", names.get(s, userID), o.when(), headline))
+		}
+
+		if err := rows.Err(); err != nil {
+			return nil, 0, fmt.Errorf("processing results: %v", err)
+		}
+
+		description := strings.Join(results, "\n\n")
+		if total == 0 {
+			description = "No messages matched your search."
+		}
+		if filters := filter.describe(); filters != "" {
+			description = fmt.Sprintf("Filtered by %s\n\n%s", filters, description)
+		}
+
+		embed := &discordgo.MessageEmbed{
+			Title:       fmt.Sprintf("Search: %s", truncate(text, 200)),
+			Description: description,
+			Color:       0xff0000,
+			Footer:      pageFooter(page, totalPages, total, "results"),
+		}
+		return embed, totalPages, nil
+	}
+}
+
+func searchHandler(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	if err := acknowledgeInteraction(s, i); err != nil {
+		return
+	}
+
+	serverID := i.GuildID
+	mainServer := os.Getenv("MAIN_SERVER")
+
+	options := optionsByName(i.ApplicationCommandData().Options)
+	var filter messageFilter
+	if serverID != mainServer {
+		filter.ServerID = serverID
+	}
+	if option, ok := options["user"]; ok {
+		filter.UserID = option.UserValue(nil).ID
+	}
+	if err := filter.applyFilterOptions(options, getGuildSettings(i.GuildID).location()); err != nil {
+		if err := sendResponse(s, i, err.Error()); err != nil {
+			log.Printf("Error sending detailed response: %v", err)
+		}
+		return
+	}
+
+	render := searchRenderer(s, options["query"].StringValue(), filter)
+	if err := sendPagedResponse(s, i, render); err != nil {
+		log.Printf("Error searching messages: %v", err)
+		if err := sendResponse(s, i, "Failed to search messages"); err != nil {
+			log.Printf("Error sending detailed response: %v", err)
+		}
+	}
+}
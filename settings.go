@@ -0,0 +1,167 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+	_ "time/tzdata" // the host may not have a time zone database
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/jackc/pgx/v4"
+)
+
+type guildSettings struct {
+	Stemming      string
+	Timezone      string
+	Announcements string // channel ID, empty when announcements are off
+}
+
+var defaultGuildSettings = guildSettings{
+	Stemming: stemOff,
+	Timezone: "UTC",
+}
+
+// location is the time zone the guild counts its days and hours in.
+func (g guildSettings) location() *time.Location {
+	loc, err := time.LoadLocation(g.Timezone)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+var settingsMu sync.RWMutex
+var settingsCache = map[string]guildSettings{}
+
+// getGuildSettings returns the settings of a guild, falling back to the
+// defaults when the guild has never been configured. Results are cached since
+// this is looked up for every incoming message.
+func getGuildSettings(guildID string) guildSettings {
+	settingsMu.RLock()
+	settings, ok := settingsCache[guildID]
+	settingsMu.RUnlock()
+	if ok {
+		return settings
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	settings = defaultGuildSettings
+	query := `SELECT stemming, timezone, COALESCE(announcements, '') FROM guild_settings WHERE serverid = $1;`
+	err := dbpool.QueryRow(ctx, query, guildID).Scan(&settings.Stemming, &settings.Timezone, &settings.Announcements)
+	if err != nil && err != pgx.ErrNoRows {
+		log.Printf("Error fetching guild settings: %v", err)
+		return settings
+	}
+
+	settingsMu.Lock()
+	settingsCache[guildID] = settings
+	settingsMu.Unlock()
+	return settings
+}
+
+// updateGuildSetting stores a single setting column for a guild and drops the
+// cached copy so the next lookup sees the new value.
+func updateGuildSetting(guildID, column string, value interface{}) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	query := fmt.Sprintf(`INSERT INTO guild_settings (serverid, %[1]s) VALUES ($1, $2)
+		ON CONFLICT (serverid) DO UPDATE SET %[1]s = EXCLUDED.%[1]s;`, pgx.Identifier{column}.Sanitize())
+	if _, err := dbpool.Exec(ctx, query, guildID, value); err != nil {
+		return err
+	}
+
+	settingsMu.Lock()
+	delete(settingsCache, guildID)
+	settingsMu.Unlock()
+	return nil
+}
+
+func stemLanguageChoices() []*discordgo.ApplicationCommandOptionChoice {
+	choices := make([]*discordgo.ApplicationCommandOptionChoice, 0, len(stemLanguages))
+	for _, language := range stemLanguages {
+		choices = append(choices, &discordgo.ApplicationCommandOptionChoice{Name: language, Value: language})
+	}
+	return choices
+}
+
+func settingsHandler(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	if err := acknowledgeInteraction(s, i); err != nil {
+		return
+	}
+
+	response := "Skill issue"
+	adminUserID := os.Getenv("ADMIN_ID")
+
+	if i.Member.User.ID != adminUserID {
+		if err := sendResponse(s, i, response); err != nil {
+			log.Printf("Error sending detailed response: %v", err)
+		}
+		return
+	}
+
+	sub := i.ApplicationCommandData().Options[0]
+	switch sub.Name {
+	case "show":
+		settings := getGuildSettings(i.GuildID)
+		announcements := "off"
+		if settings.Announcements != "" {
+			announcements = fmt.Sprintf("<#%s>", settings.Announcements)
+		}
+		response = fmt.Sprintf("Settings for this server:\n> **stemming**: %s\n> **timezone**: %s\n> **announcements**: %s", settings.Stemming, settings.Timezone, announcements)
+
+	case "stemming":
+		language := sub.Options[0].StringValue()
+		if err := updateGuildSetting(i.GuildID, "stemming", language); err != nil {
+			response = fmt.Sprintf("Failed to update stemming: %v", err)
+		} else {
+			response = fmt.Sprintf("Stemming set to **%s**. This only affects new messages.", language)
+		}
+
+	case "announcements":
+		channelID := ""
+		if option, ok := optionsByName(sub.Options)["channel"]; ok {
+			channelID = option.Value.(string)
+		}
+		if err := updateGuildSetting(i.GuildID, "announcements", channelID); err != nil {
+			response = fmt.Sprintf("Failed to update announcements: %v", err)
+		} else if channelID == "" {
+			response = "Announcements are off."
+		} else {
+			response = fmt.Sprintf("Announcements go to <#%s>.", channelID)
+		}
+
+	case "timezone":
+		zone := sub.Options[0].StringValue()
+		loc, err := time.LoadLocation(zone)
+		if err != nil || zone == "" || zone == "Local" {
+			response = fmt.Sprintf("Unknown time zone **%s**. Use a name like _Europe/Oslo_ or _UTC_.", zone)
+			break
+		}
+		if err := updateGuildSetting(i.GuildID, "timezone", loc.String()); err != nil {
+			response = fmt.Sprintf("Failed to update time zone: %v", err)
+			break
+		}
+		response = fmt.Sprintf("Time zone set to **%s**. It is %s there now.", loc.String(), time.Now().In(loc).Format("15:04"))
+
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		if err := rescheduleGuild(ctx, i.GuildID); err != nil {
+			log.Printf("Error rescheduling after time zone change: %v", err)
+			response += " Failed to move the schedules to the new time zone, see /schedule list."
+		}
+		if err := recomputeStreaks(ctx, i.GuildID); err != nil {
+			log.Printf("Error recomputing streaks after time zone change: %v", err)
+			response += " Failed to recount the streaks, try /streaks recompute."
+		}
+	}
+
+	if err := sendResponse(s, i, response); err != nil {
+		log.Printf("Error sending detailed response: %v", err)
+	}
+}
@@ -0,0 +1,576 @@
+package main
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Stemming languages a guild can choose between. stemOff keeps the plain
+// substring matching the bot has always used.
+const (
+	stemOff       = "off"
+	stemEnglish   = "english"
+	stemNorwegian = "norwegian"
+)
+
+var stemLanguages = []string{stemOff, stemEnglish, stemNorwegian}
+
+// stemWord lowercases word, maps known irregular forms to their base form and
+// runs it through the snowball stemmer for the given language.
+func stemWord(language, word string) string {
+	word = strings.ToLower(word)
+	switch language {
+	case stemEnglish:
+		if base, ok := englishIrregulars[word]; ok {
+			word = base
+		}
+		return stemEnglishWord(word)
+	case stemNorwegian:
+		if base, ok := norwegianIrregulars[word]; ok {
+			word = base
+		}
+		return stemNorwegianWord(word)
+	}
+	return word
+}
+
+// tokenize splits a message into lowercase words, dropping punctuation so that
+// "word," and "word!" stem the same way as "word".
+func tokenize(content string) []string {
+	return strings.FieldsFunc(strings.ToLower(content), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r) && r != '\''
+	})
+}
+
+var englishIrregulars = map[string]string{
+	"was":        "be",
+	"were":       "be",
+	"been":       "be",
+	"am":         "be",
+	"is":         "be",
+	"are":        "be",
+	"did":        "do",
+	"done":       "do",
+	"does":       "do",
+	"went":       "go",
+	"gone":       "go",
+	"ran":        "run",
+	"ate":        "eat",
+	"eaten":      "eat",
+	"saw":        "see",
+	"seen":       "see",
+	"took":       "take",
+	"taken":      "take",
+	"gave":       "give",
+	"given":      "give",
+	"came":       "come",
+	"got":        "get",
+	"gotten":     "get",
+	"made":       "make",
+	"said":       "say",
+	"knew":       "know",
+	"known":      "know",
+	"thought":    "think",
+	"bought":     "buy",
+	"brought":    "bring",
+	"caught":     "catch",
+	"taught":     "teach",
+	"fought":     "fight",
+	"sought":     "seek",
+	"fell":       "fall",
+	"fallen":     "fall",
+	"felt":       "feel",
+	"kept":       "keep",
+	"left":       "leave",
+	"lost":       "lose",
+	"met":        "meet",
+	"paid":       "pay",
+	"sold":       "sell",
+	"told":       "tell",
+	"sent":       "send",
+	"spent":      "spend",
+	"slept":      "sleep",
+	"stole":      "steal",
+	"stolen":     "steal",
+	"drank":      "drink",
+	"drunk":      "drink",
+	"drove":      "drive",
+	"driven":     "drive",
+	"wrote":      "write",
+	"written":    "write",
+	"rode":       "ride",
+	"ridden":     "ride",
+	"spoke":      "speak",
+	"spoken":     "speak",
+	"broke":      "break",
+	"broken":     "break",
+	"chose":      "choose",
+	"chosen":     "choose",
+	"froze":      "freeze",
+	"frozen":     "freeze",
+	"woke":       "wake",
+	"woken":      "wake",
+	"sang":       "sing",
+	"sung":       "sing",
+	"swam":       "swim",
+	"swum":       "swim",
+	"began":      "begin",
+	"begun":      "begin",
+	"sat":        "sit",
+	"stood":      "stand",
+	"understood": "understand",
+	"won":        "win",
+	"hid":        "hide",
+	"hidden":     "hide",
+	"bit":        "bite",
+	"bitten":     "bite",
+	"blew":       "blow",
+	"blown":      "blow",
+	"flew":       "fly",
+	"flown":      "fly",
+	"grew":       "grow",
+	"grown":      "grow",
+	"threw":      "throw",
+	"thrown":     "throw",
+	"shook":      "shake",
+	"shaken":     "shake",
+	"wore":       "wear",
+	"worn":       "wear",
+	"tore":       "tear",
+	"torn":       "tear",
+	"men":        "man",
+	"women":      "woman",
+	"children":   "child",
+	"feet":       "foot",
+	"teeth":      "tooth",
+	"geese":      "goose",
+	"mice":       "mouse",
+	"lice":       "louse",
+	"people":     "person",
+	"better":     "good",
+	"best":       "good",
+	"worse":      "bad",
+	"worst":      "bad",
+}
+
+var norwegianIrregulars = map[string]string{
+	"er":      "være",
+	"var":     "være",
+	"vært":    "være",
+	"har":     "ha",
+	"hadde":   "ha",
+	"hatt":    "ha",
+	"gikk":    "gå",
+	"gått":    "gå",
+	"går":     "gå",
+	"fikk":    "få",
+	"fått":    "få",
+	"får":     "få",
+	"sa":      "si",
+	"sagt":    "si",
+	"sier":    "si",
+	"gjorde":  "gjøre",
+	"gjort":   "gjøre",
+	"gjør":    "gjøre",
+	"tok":     "ta",
+	"tatt":    "ta",
+	"tar":     "ta",
+	"så":      "se",
+	"sett":    "se",
+	"ser":     "se",
+	"kom":     "komme",
+	"kommet":  "komme",
+	"ble":     "bli",
+	"blitt":   "bli",
+	"blir":    "bli",
+	"visste":  "vite",
+	"visst":   "vite",
+	"vet":     "vite",
+	"ga":      "gi",
+	"gav":     "gi",
+	"gitt":    "gi",
+	"gir":     "gi",
+	"lo":      "le",
+	"ledd":    "le",
+	"drakk":   "drikke",
+	"drukket": "drikke",
+	"spiste":  "spise",
+	"skrev":   "skrive",
+	"skrevet": "skrive",
+	"sov":     "sove",
+	"sovet":   "sove",
+	"slo":     "slå",
+	"slått":   "slå",
+	"sto":     "stå",
+	"stod":    "stå",
+	"stått":   "stå",
+	"satt":    "sitte",
+	"sittet":  "sitte",
+	"lå":      "ligge",
+	"ligget":  "ligge",
+	"løy":     "lyve",
+	"løyet":   "lyve",
+	"brøt":    "bryte",
+	"brutt":   "bryte",
+	"frøs":    "fryse",
+	"frosset": "fryse",
+	"menn":    "mann",
+	"kvinner": "kvinne",
+	"bøker":   "bok",
+	"føtter":  "fot",
+	"tenner":  "tann",
+	"hender":  "hånd",
+	"netter":  "natt",
+	"døtre":   "datter",
+	"brødre":  "bror",
+	"bedre":   "god",
+	"best":    "god",
+	"verre":   "dårlig",
+	"verst":   "dårlig",
+}
+
+// English (Porter2) snowball stemmer.
+
+var englishExceptions = map[string]string{
+	"skis": "ski", "skies": "sky", "dying": "die", "lying": "lie", "tying": "tie",
+	"idly": "idl", "gently": "gentl", "ugly": "ugli", "early": "earli",
+	"only": "onli", "singly": "singl",
+	"sky": "sky", "news": "news", "howe": "howe", "atlas": "atlas",
+	"cosmos": "cosmos", "bias": "bias", "andes": "andes",
+}
+
+var englishStep1aInvariants = map[string]bool{
+	"inning": true, "outing": true, "canning": true, "herring": true,
+	"earring": true, "proceed": true, "exceed": true, "succeed": true,
+}
+
+func isEnglishVowel(r rune) bool {
+	switch r {
+	case 'a', 'e', 'i', 'o', 'u', 'y':
+		return true
+	}
+	return false
+}
+
+func stemEnglishWord(word string) string {
+	if len([]rune(word)) <= 2 {
+		return word
+	}
+	if stem, ok := englishExceptions[word]; ok {
+		return stem
+	}
+
+	w := []rune(strings.TrimPrefix(word, "'"))
+	if len(w) == 0 {
+		return word
+	}
+	for k, r := range w {
+		if r == 'y' && (k == 0 || isEnglishVowel(w[k-1])) {
+			w[k] = 'Y'
+		}
+	}
+
+	r1, r2 := englishRegions(w)
+
+	w = englishStep0(w)
+	w = englishStep1a(w)
+	if englishStep1aInvariants[string(w)] {
+		return string(w)
+	}
+	w = englishStep1b(w, r1)
+	w = englishStep1c(w)
+	w = englishStep2(w, r1)
+	w = englishStep3(w, r1, r2)
+	w = englishStep4(w, r2)
+	w = englishStep5(w, r1, r2)
+
+	return strings.ReplaceAll(string(w), "Y", "y")
+}
+
+func englishRegions(w []rune) (int, int) {
+	s := string(w)
+	r1 := len(w)
+	switch {
+	case strings.HasPrefix(s, "gener"), strings.HasPrefix(s, "arsen"):
+		r1 = 5
+	case strings.HasPrefix(s, "commun"):
+		r1 = 6
+	default:
+		r1 = regionAfter(w, 0, isEnglishVowel)
+	}
+	return r1, regionAfter(w, r1, isEnglishVowel)
+}
+
+// regionAfter returns the index just past the first non-vowel that follows a
+// vowel, starting the search at from.
+func regionAfter(w []rune, from int, isVowel func(rune) bool) int {
+	for k := from + 1; k < len(w); k++ {
+		if !isVowel(w[k]) && isVowel(w[k-1]) {
+			return k + 1
+		}
+	}
+	return len(w)
+}
+
+func hasSuffix(w []rune, suffix string) bool {
+	return strings.HasSuffix(string(w), suffix)
+}
+
+// longestSuffix returns the longest of the given suffixes that w ends with.
+func longestSuffix(w []rune, suffixes ...string) string {
+	found := ""
+	for _, suffix := range suffixes {
+		if len(suffix) > len(found) && hasSuffix(w, suffix) {
+			found = suffix
+		}
+	}
+	return found
+}
+
+func replaceSuffix(w []rune, suffix, replacement string) []rune {
+	return append(w[:len(w)-len([]rune(suffix))], []rune(replacement)...)
+}
+
+func containsVowel(w []rune, isVowel func(rune) bool) bool {
+	for _, r := range w {
+		if isVowel(r) {
+			return true
+		}
+	}
+	return false
+}
+
+func isShortSyllableAt(w []rune, end int) bool {
+	// end is the index of the last rune of the syllable.
+	if end == 1 {
+		return isEnglishVowel(w[0]) && !isEnglishVowel(w[1])
+	}
+	if end < 2 {
+		return false
+	}
+	last := w[end]
+	return !isEnglishVowel(w[end-2]) && isEnglishVowel(w[end-1]) &&
+		!isEnglishVowel(last) && last != 'w' && last != 'x' && last != 'Y'
+}
+
+func isShortEnglishWord(w []rune, r1 int) bool {
+	return r1 >= len(w) && isShortSyllableAt(w, len(w)-1)
+}
+
+func englishStep0(w []rune) []rune {
+	if suffix := longestSuffix(w, "'s'", "'s", "'"); suffix != "" {
+		return replaceSuffix(w, suffix, "")
+	}
+	return w
+}
+
+func englishStep1a(w []rune) []rune {
+	switch longestSuffix(w, "sses", "ied", "ies", "us", "ss", "s") {
+	case "sses":
+		return replaceSuffix(w, "sses", "ss")
+	case "ied", "ies":
+		if len(w) > 4 {
+			return replaceSuffix(w, "ies", "i")
+		}
+		return replaceSuffix(w, "ies", "ie")
+	case "s":
+		if containsVowel(w[:len(w)-2], isEnglishVowel) {
+			return w[:len(w)-1]
+		}
+	}
+	return w
+}
+
+func englishStep1b(w []rune, r1 int) []rune {
+	suffix := longestSuffix(w, "eedly", "ingly", "edly", "eed", "ing", "ed")
+	switch suffix {
+	case "":
+		return w
+	case "eed", "eedly":
+		if len(w)-len(suffix) >= r1 {
+			return replaceSuffix(w, suffix, "ee")
+		}
+		return w
+	}
+
+	stem := w[:len(w)-len(suffix)]
+	if !containsVowel(stem, isEnglishVowel) {
+		return w
+	}
+	w = stem
+	switch {
+	case hasSuffix(w, "at"), hasSuffix(w, "bl"), hasSuffix(w, "iz"):
+		return append(w, 'e')
+	case longestSuffix(w, "bb", "dd", "ff", "gg", "mm", "nn", "pp", "rr", "tt") != "":
+		return w[:len(w)-1]
+	case isShortEnglishWord(w, r1):
+		return append(w, 'e')
+	}
+	return w
+}
+
+func englishStep1c(w []rune) []rune {
+	n := len(w)
+	if n > 2 && (w[n-1] == 'y' || w[n-1] == 'Y') && !isEnglishVowel(w[n-2]) {
+		w[n-1] = 'i'
+	}
+	return w
+}
+
+var englishStep2Suffixes = map[string]string{
+	"tional": "tion", "enci": "ence", "anci": "ance", "abli": "able",
+	"entli": "ent", "izer": "ize", "ization": "ize", "ational": "ate",
+	"ation": "ate", "ator": "ate", "alism": "al", "aliti": "al", "alli": "al",
+	"fulness": "ful", "ousli": "ous", "ousness": "ous", "iveness": "ive",
+	"iviti": "ive", "biliti": "ble", "bli": "ble", "ogi": "og", "fulli": "ful",
+	"lessli": "less", "li": "",
+}
+
+func englishStep2(w []rune, r1 int) []rune {
+	suffix := longestSuffixOf(w, englishStep2Suffixes)
+	if suffix == "" || len(w)-len(suffix) < r1 {
+		return w
+	}
+	switch suffix {
+	case "ogi":
+		if !hasSuffix(w, "logi") {
+			return w
+		}
+	case "li":
+		if len(w) < 3 || !strings.ContainsRune("cdeghkmnrt", w[len(w)-3]) {
+			return w
+		}
+	}
+	return replaceSuffix(w, suffix, englishStep2Suffixes[suffix])
+}
+
+var englishStep3Suffixes = map[string]string{
+	"tional": "tion", "ational": "ate", "alize": "al", "icate": "ic",
+	"iciti": "ic", "ical": "ic", "ful": "", "ness": "", "ative": "",
+}
+
+func englishStep3(w []rune, r1, r2 int) []rune {
+	suffix := longestSuffixOf(w, englishStep3Suffixes)
+	if suffix == "" || len(w)-len(suffix) < r1 {
+		return w
+	}
+	if suffix == "ative" && len(w)-len(suffix) < r2 {
+		return w
+	}
+	return replaceSuffix(w, suffix, englishStep3Suffixes[suffix])
+}
+
+func englishStep4(w []rune, r2 int) []rune {
+	suffix := longestSuffix(w, "al", "ance", "ence", "er", "ic", "able", "ible",
+		"ant", "ement", "ment", "ent", "ism", "ate", "iti", "ous", "ive", "ize", "ion")
+	if suffix == "" || len(w)-len(suffix) < r2 {
+		return w
+	}
+	if suffix == "ion" {
+		n := len(w) - 3
+		if n < 1 || (w[n-1] != 's' && w[n-1] != 't') {
+			return w
+		}
+	}
+	return replaceSuffix(w, suffix, "")
+}
+
+func englishStep5(w []rune, r1, r2 int) []rune {
+	n := len(w)
+	if n == 0 {
+		return w
+	}
+	switch w[n-1] {
+	case 'e':
+		if n-1 >= r2 || (n-1 >= r1 && !isShortSyllableAt(w, n-2)) {
+			return w[:n-1]
+		}
+	case 'l':
+		if n-1 >= r2 && n > 1 && w[n-2] == 'l' {
+			return w[:n-1]
+		}
+	}
+	return w
+}
+
+func longestSuffixOf(w []rune, suffixes map[string]string) string {
+	found := ""
+	for suffix := range suffixes {
+		if len(suffix) > len(found) && hasSuffix(w, suffix) {
+			found = suffix
+		}
+	}
+	return found
+}
+
+// Norwegian (bokmål) snowball stemmer.
+
+func isNorwegianVowel(r rune) bool {
+	switch r {
+	case 'a', 'e', 'i', 'o', 'u', 'y', 'æ', 'å', 'ø':
+		return true
+	}
+	return false
+}
+
+var norwegianStep1Suffixes = []string{
+	"a", "e", "ede", "ande", "ende", "ane", "ene", "hetene", "en", "heten",
+	"ar", "er", "heter", "as", "es", "edes", "endes", "enes", "hetenes", "ens",
+	"hetens", "ers", "ets", "et", "het", "ast", "s", "erte", "ert",
+}
+
+var norwegianStep3Suffixes = []string{
+	"leg", "eleg", "ig", "eig", "lig", "elig", "els", "lov", "elov", "slov", "hetslov",
+}
+
+func stemNorwegianWord(word string) string {
+	w := []rune(word)
+	r1 := regionAfter(w, 0, isNorwegianVowel)
+	if r1 < 3 {
+		r1 = 3
+	}
+	if r1 > len(w) {
+		return word
+	}
+
+	// Step 1
+	if suffix := longestSuffixRunes(w, r1, norwegianStep1Suffixes); suffix != "" {
+		switch suffix {
+		case "s":
+			n := len(w) - 1
+			if n > 0 && (strings.ContainsRune("bcdfghjlmnoprtvyz", w[n-1]) ||
+				(w[n-1] == 'k' && n > 1 && !isNorwegianVowel(w[n-2]))) {
+				w = w[:n]
+			}
+		case "erte", "ert":
+			w = replaceSuffix(w, suffix, "er")
+		default:
+			w = replaceSuffix(w, suffix, "")
+		}
+	}
+
+	// Step 2
+	if (hasSuffix(w, "dt") || hasSuffix(w, "vt")) && len(w)-2 >= r1 {
+		w = w[:len(w)-1]
+	}
+
+	// Step 3
+	if suffix := longestSuffixRunes(w, r1, norwegianStep3Suffixes); suffix != "" {
+		w = replaceSuffix(w, suffix, "")
+	}
+
+	return string(w)
+}
+
+// longestSuffixRunes returns the longest suffix that lies entirely within the
+// region starting at r1.
+func longestSuffixRunes(w []rune, r1 int, suffixes []string) string {
+	found := ""
+	for _, suffix := range suffixes {
+		n := len([]rune(suffix))
+		if n > len([]rune(found)) && len(w)-n >= r1 && hasSuffix(w, suffix) {
+			found = suffix
+		}
+	}
+	return found
+}
@@ -0,0 +1,110 @@
+package main
+
+import "testing"
+
+// The expected stems are the outputs of the reference snowball stemmers.
+
+func TestStemEnglishWord(t *testing.T) {
+	tests := map[string]string{
+		// From the Porter2 sample vocabulary.
+		"consign": "consign", "consigned": "consign", "consigning": "consign", "consignment": "consign",
+		"consist": "consist", "consisted": "consist", "consistency": "consist", "consistent": "consist",
+		"consistently": "consist", "consisting": "consist", "consists": "consist",
+		"consolation": "consol", "consolations": "consol", "consolatory": "consolatori",
+		"console": "consol", "consoled": "consol", "consoles": "consol", "consoling": "consol",
+		"consolingly": "consol", "consols": "consol",
+		"consolidate": "consolid", "consolidated": "consolid", "consolidating": "consolid",
+		"consonant": "conson", "consort": "consort", "consorted": "consort", "consorting": "consort",
+		"conspicuous": "conspicu", "conspicuously": "conspicu", "conspiracy": "conspiraci",
+		"conspirator": "conspir", "conspirators": "conspir", "conspire": "conspir",
+		"conspired": "conspir", "conspiring": "conspir",
+		"constable": "constabl", "constables": "constabl", "constance": "constanc",
+		"constancy": "constanc", "constant": "constant",
+		"knack": "knack", "knackeries": "knackeri", "knacks": "knack", "knag": "knag",
+		"knave": "knave", "knaves": "knave", "knavish": "knavish", "kneaded": "knead",
+		"kneading": "knead", "knee": "knee", "kneel": "kneel", "kneeled": "kneel",
+		"kneeling": "kneel", "kneels": "kneel", "knees": "knee", "knell": "knell",
+		"knelt": "knelt", "knew": "knew", "knick": "knick", "knif": "knif", "knife": "knife",
+		"knight": "knight", "knightly": "knight", "knights": "knight", "knit": "knit",
+		"knits": "knit", "knitted": "knit", "knitting": "knit", "knives": "knive",
+		"knob": "knob", "knobs": "knob", "knock": "knock", "knocked": "knock",
+		"knocker": "knocker", "knockers": "knocker", "knocking": "knock", "knocks": "knock",
+		"knopp": "knopp", "knot": "knot", "knots": "knot",
+
+		// R1 starts after gener, arsen and commun.
+		"generate": "generat", "generated": "generat", "generating": "generat",
+		"general": "general", "generally": "general", "generic": "generic",
+		"generically": "generic", "generous": "generous", "generously": "generous",
+
+		// Step 1.
+		"caresses": "caress", "ponies": "poni", "ties": "tie", "cats": "cat", "gas": "gas",
+		"this": "this", "kiwis": "kiwi", "cries": "cri", "cried": "cri", "agreed": "agre",
+		"feed": "feed", "hopping": "hop", "played": "play", "saying": "say",
+		"happy": "happi", "fly": "fli", "flies": "fli", "fluently": "fluentli",
+
+		// Short words, where R1 and R2 are empty.
+		"by": "by", "say": "say", "bed": "bed", "shed": "shed", "hoping": "hope",
+		"youth": "youth",
+
+		// Exceptions and words left alone after step 1a.
+		"skies": "sky", "sky": "sky", "dying": "die", "news": "news",
+		"inning": "inning", "proceed": "proceed",
+	}
+	for word, want := range tests {
+		if got := stemEnglishWord(word); got != want {
+			t.Errorf("stemEnglishWord(%q) = %q, want %q", word, got, want)
+		}
+	}
+}
+
+func TestStemNorwegianWord(t *testing.T) {
+	tests := map[string]string{
+		// Step 1.
+		"huset": "hus", "husene": "hus", "bilen": "bil", "bilene": "bil", "bilers": "bil",
+		"gutter": "gutt", "gutten": "gutt", "hoppet": "hopp", "snakket": "snakk",
+		"kaster": "kast", "huskes": "husk", "barnets": "barn", "bøkene": "bøk",
+		"studert": "studer", "studerte": "studer",
+
+		// s is only removed after a valid s-ending; k must follow a consonant.
+		"dags": "dag", "kirkes": "kirk", "marks": "mark", "taks": "taks",
+
+		// Steps 2 and 3.
+		"forbudt": "forbud", "kjærlighet": "kjær", "vennlig": "venn", "farlig": "far",
+
+		// R1 starts at the third letter at the earliest, and is empty in short
+		// words.
+		"godt": "godt", "ærlig": "ærl", "hus": "hus", "ost": "ost", "is": "is",
+	}
+	for word, want := range tests {
+		if got := stemNorwegianWord(word); got != want {
+			t.Errorf("stemNorwegianWord(%q) = %q, want %q", word, got, want)
+		}
+	}
+}
+
+func TestStemWordIrregulars(t *testing.T) {
+	tests := []struct {
+		language, word, want string
+	}{
+		{stemEnglish, "went", "go"},
+		{stemEnglish, "Ran", "run"},
+		{stemEnglish, "taken", "take"},
+		{stemEnglish, "children", "child"},
+		{stemEnglish, "mice", "mous"},
+		{stemEnglish, "people", "person"},
+		{stemEnglish, "better", "good"},
+		{stemEnglish, "Knocking", "knock"},
+		{stemNorwegian, "gikk", "gå"},
+		{stemNorwegian, "Menn", "mann"},
+		{stemNorwegian, "bøker", "bok"},
+		{stemNorwegian, "hender", "hånd"},
+		{stemNorwegian, "bedre", "god"},
+		{stemNorwegian, "Huset", "hus"},
+		{stemOff, "Went", "went"},
+	}
+	for _, tt := range tests {
+		if got := stemWord(tt.language, tt.word); got != tt.want {
+			t.Errorf("stemWord(%q, %q) = %q, want %q", tt.language, tt.word, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,262 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// streaksJob is the name of the daily broken streak notices in the schedules
+// table. It runs just after midnight, when yesterday's streaks are settled.
+const (
+	streaksJob  = "streaks"
+	streaksCron = "5 0 * * *"
+)
+
+// longStreak is how many days a streak must have lasted for its end to be
+// worth a notice.
+const longStreak = 7
+
+// streakLeaderboardSize is how many users each streak leaderboard shows.
+const streakLeaderboardSize = 10
+
+// A streak is a run of consecutive days, in the guild's time zone, with at
+// least one flagged message. The streaks table keeps the latest run of each
+// user as current and last_day; the run is still going if last_day is today
+// or yesterday.
+
+// guildDay is the date t falls on in the guild's time zone, as midnight UTC so
+// it compares and stores like a Postgres date.
+func guildDay(guildID string, t time.Time) time.Time {
+	local := t.In(getGuildSettings(guildID).location())
+	return time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// updateStreak counts the day a just stored message was sent towards its
+// author's streak. Messages older than the latest counted day, as when
+// backtracking, are left for recomputeStreaks.
+func updateStreak(msg *discordgo.Message, guildID string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	query := `INSERT INTO streaks (serverid, userid, current, best, last_day) VALUES ($1, $2, 1, 1, $3)
+		ON CONFLICT (serverid, userid) DO UPDATE SET
+			current = CASE WHEN EXCLUDED.last_day = streaks.last_day + 1 THEN streaks.current + 1
+				WHEN EXCLUDED.last_day > streaks.last_day + 1 THEN 1 ELSE streaks.current END,
+			best = GREATEST(streaks.best, CASE WHEN EXCLUDED.last_day = streaks.last_day + 1 THEN streaks.current + 1 ELSE 1 END),
+			notified = streaks.notified AND EXCLUDED.last_day <= streaks.last_day,
+			last_day = GREATEST(streaks.last_day, EXCLUDED.last_day);`
+	if _, err := dbpool.Exec(ctx, query, guildID, msg.Author.ID, guildDay(guildID, msg.Timestamp)); err != nil {
+		log.Printf("Error updating streak: %v", err)
+	}
+}
+
+// recomputeStreaks rebuilds the streaks of a guild from all its stored
+// messages, for after backtracking or a time zone change. The badges are
+// awarded again afterwards, as rebuilt streaks may have earned some.
+func recomputeStreaks(ctx context.Context, guildID string) error {
+	tx, err := dbpool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `DELETE FROM streaks WHERE serverid = $1;`, guildID); err != nil {
+		return err
+	}
+	query := `WITH days AS (SELECT DISTINCT userID, (timestamp AT TIME ZONE $2)::date AS day FROM messages WHERE serverID = $1),
+		islands AS (SELECT userID, day, day - (ROW_NUMBER() OVER (PARTITION BY userID ORDER BY day))::int AS grp FROM days),
+		runs AS (SELECT userID, COUNT(*) AS length, MAX(day) AS last_day FROM islands GROUP BY userID, grp)
+		INSERT INTO streaks (serverid, userid, current, best, last_day, notified)
+		SELECT $1, userID, (ARRAY_AGG(length ORDER BY last_day DESC))[1], MAX(length), MAX(last_day), MAX(last_day) < $3
+		FROM runs GROUP BY userID;`
+	// Streaks that ended before today are marked as notified, so rebuilding
+	// does not announce old breaks again.
+	yesterday := guildDay(guildID, time.Now()).AddDate(0, 0, -1)
+	if _, err := tx.Exec(ctx, query, guildID, getGuildSettings(guildID).location().String(), yesterday); err != nil {
+		return err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return err
+	}
+	return recomputeAchievements(ctx, guildID, time.Now())
+}
+
+type streak struct {
+	UserID  string
+	Current int
+	Best    int
+	LastDay time.Time
+}
+
+// fetchStreakLeaders returns the longest running streaks and the longest
+// streaks ever in a guild, counting today from now.
+func fetchStreakLeaders(ctx context.Context, guildID string, now time.Time) ([]streak, []streak, error) {
+	yesterday := guildDay(guildID, now).AddDate(0, 0, -1)
+	fetch := func(query string, args ...interface{}) ([]streak, error) {
+		rows, err := dbpool.Query(ctx, query, args...)
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+
+		var streaks []streak
+		for rows.Next() {
+			var st streak
+			if err := rows.Scan(&st.UserID, &st.Current, &st.Best, &st.LastDay); err != nil {
+				return nil, err
+			}
+			streaks = append(streaks, st)
+		}
+		return streaks, rows.Err()
+	}
+
+	current, err := fetch(`SELECT userid, current, best, last_day FROM streaks WHERE serverid = $1 AND last_day >= $2
+		ORDER BY current DESC, last_day, userid LIMIT $3;`, guildID, yesterday, streakLeaderboardSize)
+	if err != nil {
+		return nil, nil, err
+	}
+	best, err := fetch(`SELECT userid, current, best, last_day FROM streaks WHERE serverid = $1
+		ORDER BY best DESC, userid LIMIT $2;`, guildID, streakLeaderboardSize)
+	return current, best, err
+}
+
+// postBrokenStreaks is the scheduled job announcing the long streaks that
+// ended because nothing was flagged yesterday. Every broken streak is
+// announced once, also when a run was missed.
+func postBrokenStreaks(s *discordgo.Session, sched schedule, at time.Time) error {
+	if sched.ChannelID == "" {
+		return fmt.Errorf("no channel configured")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	yesterday := guildDay(sched.ServerID, at).AddDate(0, 0, -1)
+	rows, err := dbpool.Query(ctx, `UPDATE streaks SET notified = true
+		WHERE serverid = $1 AND last_day < $2 AND current >= $3 AND NOT notified
+		RETURNING userid, current;`, sched.ServerID, yesterday, longStreak)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var lines []string
+	for rows.Next() {
+		var userID string
+		var length int
+		if err := rows.Scan(&userID, &length); err != nil {
+			return err
+		}
+		lines = append(lines, fmt.Sprintf("💔 <@%s> ended a **%d** day streak.", userID, length))
+	}
+	if err := rows.Err(); err != nil || len(lines) == 0 {
+		return err
+	}
+
+	_, err = s.ChannelMessageSendComplex(sched.ChannelID, &discordgo.MessageSend{
+		Content:         truncate(strings.Join(lines, "\n"), 2000),
+		AllowedMentions: &discordgo.MessageAllowedMentions{},
+	})
+	return err
+}
+
+func streaksHandler(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	if err := acknowledgeInteraction(s, i); err != nil {
+		return
+	}
+
+	sub := i.ApplicationCommandData().Options[0]
+	if sub.Name == "top" {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		current, best, err := fetchStreakLeaders(ctx, i.GuildID, time.Now())
+		if err != nil {
+			log.Printf("Error fetching streaks: %v", err)
+			if err := sendResponse(s, i, "Failed to fetch streaks"); err != nil {
+				log.Printf("Error sending detailed response: %v", err)
+			}
+			return
+		}
+
+		names := usernameCache{}
+		lines := func(streaks []streak, days func(streak) int, none string) string {
+			var out []string
+			for k, st := range streaks {
+				out = append(out, fmt.Sprintf("%d. %s: **%d** days", k+1, names.get(s, st.UserID), days(st)))
+			}
+			if len(out) == 0 {
+				return none
+			}
+			return strings.Join(out, "\n")
+		}
+		embed := &discordgo.MessageEmbed{
+			Title: "Streaks",
+			Color: 0xff0000,
+			Fields: []*discordgo.MessageEmbedField{
+				{Name: "🔥 Running", Value: lines(current, func(st streak) int { return st.Current }, "Nobody is on a streak."), Inline: true},
+				{Name: "🏆 Longest ever", Value: lines(best, func(st streak) int { return st.Best }, "No streaks yet."), Inline: true},
+			},
+			Footer: &discordgo.MessageEmbedFooter{Text: fmt.Sprintf("Days are counted in %s time", getGuildSettings(i.GuildID).Timezone)},
+		}
+		if err := sendEmbedResponse(s, i, embed); err != nil {
+			log.Printf("Error sending detailed response: %v", err)
+		}
+		return
+	}
+
+	response := "Skill issue"
+	adminUserID := os.Getenv("ADMIN_ID")
+
+	if i.Member.User.ID != adminUserID {
+		if err := sendResponse(s, i, response); err != nil {
+			log.Printf("Error sending detailed response: %v", err)
+		}
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	switch sub.Name {
+	case "notices":
+		option, ok := optionsByName(sub.Options)["channel"]
+		if !ok {
+			if _, err := dbpool.Exec(ctx, `DELETE FROM schedules WHERE serverid = $1 AND job = $2;`, i.GuildID, streaksJob); err != nil {
+				log.Printf("Error deleting streaks schedule: %v", err)
+				response = "Failed to turn the streak notices off"
+				break
+			}
+			response = "Streak notices are off."
+			break
+		}
+
+		channelID := option.Value.(string)
+		if _, err := saveSchedule(ctx, i.GuildID, streaksJob, streaksCron, channelID); err != nil {
+			log.Printf("Error saving streaks schedule: %v", err)
+			response = fmt.Sprintf("Failed to schedule the streak notices: %v", err)
+			break
+		}
+		recordAudit(i.GuildID, i.Member.User.ID, "streaks notices", "channel="+channelID)
+		response = fmt.Sprintf("When a streak of %d days or more ends, <#%s> hears about it.", longStreak, channelID)
+
+	case "recompute":
+		if err := recomputeStreaks(ctx, i.GuildID); err != nil {
+			log.Printf("Error recomputing streaks: %v", err)
+			response = "Failed to recompute the streaks"
+			break
+		}
+		recordAudit(i.GuildID, i.Member.User.ID, "streaks recompute", "")
+		response = "Streaks recomputed from the stored messages."
+	}
+
+	if err := sendResponse(s, i, response); err != nil {
+		log.Printf("Error sending detailed response: %v", err)
+	}
+}
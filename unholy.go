@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math/rand"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// unholyPageSize is how many messages one page of /unholy shows. Guild names,
+// messages and the filter header are cut to the lengths below, so a full page
+// stays under Discord's 4096 character limit for embed descriptions.
+const (
+	unholyPageSize      = 8
+	unholyMessageLength = 250
+	unholyGuildLength   = 40
+	unholyFilterLength  = 300
+)
+
+// Sort orders of /unholy.
+const (
+	sortOldest = "oldest"
+	sortNewest = "newest"
+	sortRandom = "random"
+)
+
+// unholyRenderer returns a pageRenderer listing the stored messages matching
+// filter. limit caps the total number of messages shown, 0 shows them all.
+func unholyRenderer(s *discordgo.Session, user *discordgo.User, filter messageFilter, order string, limit int) pageRenderer {
+	guildNames := make(map[string]string)
+
+	orderBy := "timestamp ASC"
+	switch order {
+	case sortNewest:
+		orderBy = "timestamp DESC"
+	case sortRandom:
+		// A fixed seed per view keeps the shuffled order stable between pages.
+		orderBy = fmt.Sprintf("md5(COALESCE(message, '') || timestamp::text || '%d')", rand.Int63())
+	}
+
+	return func(page int) (*discordgo.MessageEmbed, int, error) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		where, args := filter.where("", nil)
+
+		var total int
+		if err := dbpool.QueryRow(ctx, `SELECT COUNT(*) FROM messages `+where, args...).Scan(&total); err != nil {
+			return nil, 0, fmt.Errorf("counting messages: %v", err)
+		}
+		if limit > 0 && total > limit {
+			total = limit
+		}
+		totalPages := (total + unholyPageSize - 1) / unholyPageSize
+		if totalPages == 0 {
+			totalPages = 1
+		}
+		if page >= totalPages {
+			page = totalPages - 1
+		}
+
+		pageSize := unholyPageSize
+		if remaining := total - page*unholyPageSize; remaining < pageSize {
+			pageSize = remaining
+		}
+
+		query := fmt.Sprintf(`SELECT serverID, COALESCE(channelID, ''), COALESCE(messageID, ''), COALESCE(message, ''), timestamp FROM messages %s ORDER BY %s LIMIT %d OFFSET %d`,
+			where, orderBy, pageSize, page*unholyPageSize)
+		rows, err := dbpool.Query(ctx, query, args...)
+		if err != nil {
+			return nil, 0, fmt.Errorf("querying messages: %v", err)
+		}
+		defer rows.Close()
+
+		var messages []string
+		for rows.Next() {
+			var o offense
+			if err := rows.Scan(&o.ServerID, &o.ChannelID, &o.MessageID, &o.Message, &o.Timestamp); err != nil {
+				log.Printf("Error scanning row: %v", err)
+				continue
+			}
+
+			guildName, ok := guildNames[o.ServerID]
+			if !ok {
+				guild, err := s.Guild(o.ServerID)
+				if err != nil {
+					log.Printf("Error fetching guild: %v", err)
+					guildName = "Unknown server"
+				} else {
+					guildName = guild.Name
+				}
+				guildNames[o.ServerID] = guildName
+			}
+
+			messageStr := fmt.Sprintf("%s: **%s**: %s", truncate(guildName, unholyGuildLength), truncate(o.Message, unholyMessageLength), o.when())
+			messages = append(messages, messageStr)
+		}
+
+		if err := rows.Err(); err != nil {
+			return nil, 0, fmt.Errorf("processing results: %v", err)
+		}
+
+		description := strings.Join(messages, "\n")
+		if filters := filter.describe(); filters != "" {
+			description = fmt.Sprintf("Filtered by %s\n\n%s", truncate(filters, unholyFilterLength), description)
+		}
+
+		embed := &discordgo.MessageEmbed{
+			Title:       fmt.Sprintf("Unholy messages of %s", user.Username),
+			Description: description,
+			Color:       0xff0000,
+			Footer:      pageFooter(page, totalPages, total, "messages"),
+		}
+		if total == 0 {
+			embed.Description = fmt.Sprintf("%v is a boring bitch. Gaslight them to join the list ;D", user.Username)
+			if filters := filter.describe(); filters != "" {
+				embed.Description = fmt.Sprintf("No messages from %v matching %s.", user.Username, filters)
+			}
+		}
+		return embed, totalPages, nil
+	}
+}
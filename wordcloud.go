@@ -0,0 +1,195 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"image"
+	"log"
+	"math"
+	"math/rand"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+const (
+	wordcloudWidth    = 900
+	wordcloudHeight   = 500
+	wordcloudMaxWords = 60
+	wordcloudMinScale = 2
+	wordcloudMaxScale = 9
+	// Below this many different words a cloud says nothing, so the words are
+	// listed instead.
+	wordcloudMinWords = 3
+)
+
+type wordCount struct {
+	Word  string
+	Count int
+}
+
+// fetchWordCounts returns the most used flagged words, most used first and
+// ties by word so the order is always the same.
+func fetchWordCounts(ctx context.Context, filter messageFilter, limit int) ([]wordCount, error) {
+	where, args := filter.where("m", nil)
+	query := fmt.Sprintf(`SELECT w.word, COUNT(*) FROM messages m
+		CROSS JOIN LATERAL UNNEST(m.wordID) AS u(wordID) JOIN words w ON w.wordID = u.wordID
+		%s GROUP BY w.word ORDER BY 2 DESC, 1 LIMIT %d;`, where, limit)
+	rows, err := dbpool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var counts []wordCount
+	for rows.Next() {
+		var c wordCount
+		if err := rows.Scan(&c.Word, &c.Count); err != nil {
+			return nil, err
+		}
+		counts = append(counts, c)
+	}
+	return counts, rows.Err()
+}
+
+// wordcloudSeed is the seed used when none is given, so asking twice for the
+// same cloud draws the same picture.
+func wordcloudSeed(parts ...string) int64 {
+	h := fnv.New64a()
+	h.Write([]byte(strings.Join(parts, "/")))
+	return int64(h.Sum64() & math.MaxInt64)
+}
+
+// renderWordcloud places the words largest first along a spiral out from the
+// middle, skipping any that no longer fit. The same words and seed always give
+// the same image.
+func renderWordcloud(counts []wordCount, seed int64) *image.RGBA {
+	img := newCanvas(wordcloudWidth, wordcloudHeight)
+	rng := rand.New(rand.NewSource(seed))
+
+	sort.SliceStable(counts, func(a, b int) bool {
+		if counts[a].Count != counts[b].Count {
+			return counts[a].Count > counts[b].Count
+		}
+		return counts[a].Word < counts[b].Word
+	})
+	min, max := counts[len(counts)-1].Count, counts[0].Count
+
+	bounds := img.Bounds().Inset(10)
+	var placed []image.Rectangle
+	// Words keep a gap of a few font pixels so neighbours never read as
+	// one word.
+	fits := func(r image.Rectangle, gap int) bool {
+		if !r.In(bounds) {
+			return false
+		}
+		for _, p := range placed {
+			if r.Inset(-gap).Overlaps(p) {
+				return false
+			}
+		}
+		return true
+	}
+
+	for _, c := range counts {
+		// Square root scaling keeps one dominant word from drowning the rest.
+		frac := 1.0
+		if max > min {
+			frac = math.Sqrt(float64(c.Count-min) / float64(max-min))
+		}
+		scale := wordcloudMinScale + int(math.Round(frac*float64(wordcloudMaxScale-wordcloudMinScale)))
+		color := paletteColors[rng.Intn(len(paletteColors))]
+		angle := rng.Float64() * 2 * math.Pi
+
+		for ; scale >= wordcloudMinScale; scale-- {
+			w, h := textWidth(c.Word, scale), textHeight(scale)
+			var spot image.Rectangle
+			found := false
+			for step := 0; step < 2000; step++ {
+				t := angle + float64(step)*0.1
+				radius := 2 * float64(step) * 0.1
+				x := wordcloudWidth/2 + int(radius*math.Cos(t)*1.6) - w/2
+				y := wordcloudHeight/2 + int(radius*math.Sin(t)) - h/2
+				spot = image.Rect(x, y, x+w, y+h)
+				if fits(spot, 3*scale) {
+					found = true
+					break
+				}
+			}
+			if found {
+				placed = append(placed, spot)
+				drawText(img, spot.Min.X, spot.Min.Y, c.Word, scale, color)
+				break
+			}
+		}
+	}
+	return img
+}
+
+func wordcloudHandler(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	if err := acknowledgeInteraction(s, i); err != nil {
+		return
+	}
+
+	options := optionsByName(i.ApplicationCommandData().Options)
+	filter := scopeFilter(i)
+	name := "everyone"
+	if option, ok := options["user"]; ok {
+		user := option.UserValue(s)
+		filter.UserID = user.ID
+		name = user.Username
+	}
+	seed := wordcloudSeed(i.GuildID, filter.UserID)
+	if option, ok := options["seed"]; ok {
+		seed = option.IntValue()
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	counts, err := fetchWordCounts(ctx, filter, wordcloudMaxWords)
+	if err != nil {
+		log.Printf("Error executing wordcloud query: %v", err)
+		if err := sendResponse(s, i, "Failed to fetch words"); err != nil {
+			log.Printf("Error sending detailed response: %v", err)
+		}
+		return
+	}
+
+	if len(counts) < wordcloudMinWords {
+		response := fmt.Sprintf("%s has not used enough different words for a cloud yet.", name)
+		if len(counts) > 0 {
+			var words []string
+			for _, c := range counts {
+				words = append(words, fmt.Sprintf("%s: %d", c.Word, c.Count))
+			}
+			response += " So far:\n" + strings.Join(words, "\n")
+		}
+		if err := sendResponse(s, i, response); err != nil {
+			log.Printf("Error sending detailed response: %v", err)
+		}
+		return
+	}
+
+	buf, err := encodePNG(renderWordcloud(counts, seed))
+	if err != nil {
+		log.Printf("Error rendering wordcloud: %v", err)
+		if err := sendResponse(s, i, "Failed to render word cloud"); err != nil {
+			log.Printf("Error sending detailed response: %v", err)
+		}
+		return
+	}
+
+	response := fmt.Sprintf("The flagged words of %s (seed %d)", name, seed)
+	file := &discordgo.File{
+		Name:        "wordcloud.png",
+		ContentType: "image/png",
+		Reader:      buf,
+	}
+	if err := sendFileResponse(s, i, response, file); err != nil {
+		log.Printf("Error sending detailed response: %v", err)
+	}
+}
@@ -0,0 +1,441 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// maxImportSize caps how much of an uploaded word list is read.
+const maxImportSize = 1 << 20
+
+// maxPreviewLines is how many words of each kind the import preview lists.
+const maxPreviewLines = 15
+
+// importEntry is a word read from an import file. Mode, Weight and Category
+// are left empty when the file does not give them, so importing does not
+// overwrite what an existing word already has.
+type importEntry struct {
+	Word     string `json:"word"`
+	Mode     string `json:"mode,omitempty"`
+	Weight   int    `json:"weight,omitempty"`
+	Category string `json:"category,omitempty"`
+}
+
+// normalize lowercases the word and validates the optional columns.
+func (e *importEntry) normalize() error {
+	e.Word = strings.ToLower(strings.TrimSpace(e.Word))
+	e.Mode = strings.ToLower(strings.TrimSpace(e.Mode))
+	e.Category = strings.TrimSpace(e.Category)
+
+	if e.Word == "" {
+		return fmt.Errorf("empty word")
+	}
+	if e.Mode != "" && e.Mode != matchContains && e.Mode != matchExact {
+		return fmt.Errorf("unknown mode %q for %q", e.Mode, e.Word)
+	}
+	if e.Weight < 0 {
+		return fmt.Errorf("negative weight for %q", e.Word)
+	}
+	return nil
+}
+
+// over returns the word as it will be stored: the columns the entry leaves
+// out are taken from old, the word as it is now, or the defaults for a new
+// word. It mirrors the upsert in importWords.
+func (e importEntry) over(old flaggedWord, exists bool) importEntry {
+	if !exists {
+		old = flaggedWord{Mode: matchContains, Weight: 1}
+	}
+	if e.Mode == "" {
+		e.Mode = old.Mode
+	}
+	if e.Weight == 0 {
+		e.Weight = old.Weight
+	}
+	if e.Category == "" {
+		e.Category = old.Category
+	}
+	return e
+}
+
+// parseWordList reads a TXT, CSV or JSON word list. Entries that fail to parse
+// are returned as problems rather than aborting the whole import.
+func parseWordList(filename string, data []byte) ([]importEntry, []string, error) {
+	var entries []importEntry
+	var problems []string
+
+	switch strings.ToLower(path.Ext(filename)) {
+	case ".json":
+		var raw []json.RawMessage
+		if err := json.Unmarshal(data, &raw); err != nil {
+			return nil, nil, fmt.Errorf("expected a JSON array: %v", err)
+		}
+		for _, item := range raw {
+			var entry importEntry
+			var word string
+			if err := json.Unmarshal(item, &word); err == nil {
+				entry.Word = word
+			} else if err := json.Unmarshal(item, &entry); err != nil {
+				problems = append(problems, fmt.Sprintf("invalid entry %s", item))
+				continue
+			}
+			entries = append(entries, entry)
+		}
+
+	case ".csv":
+		reader := csv.NewReader(bytes.NewReader(data))
+		reader.FieldsPerRecord = -1
+		reader.TrimLeadingSpace = true
+		// Records are read one at a time to keep the line each starts on, which
+		// counts the header and blank lines.
+		var records [][]string
+		var lines []int
+		for {
+			record, err := reader.Read()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return nil, nil, fmt.Errorf("invalid CSV: %v", err)
+			}
+			line, _ := reader.FieldPos(0)
+			records = append(records, record)
+			lines = append(lines, line)
+		}
+
+		columns := []string{"word", "mode", "weight", "category"}
+		if len(records) > 0 && strings.EqualFold(strings.TrimSpace(records[0][0]), "word") {
+			columns = records[0]
+			records, lines = records[1:], lines[1:]
+		}
+		for n, record := range records {
+			line := lines[n]
+			var entry importEntry
+			var problem string
+			for k, value := range record {
+				if k >= len(columns) {
+					break
+				}
+				switch strings.ToLower(strings.TrimSpace(columns[k])) {
+				case "word":
+					entry.Word = value
+				case "mode":
+					entry.Mode = value
+				case "weight":
+					if strings.TrimSpace(value) == "" {
+						continue
+					}
+					weight, err := strconv.Atoi(strings.TrimSpace(value))
+					if err != nil {
+						problem = fmt.Sprintf("line %d: invalid weight %q", line, value)
+						continue
+					}
+					entry.Weight = weight
+				case "category":
+					entry.Category = value
+				}
+			}
+			if problem != "" {
+				problems = append(problems, problem)
+				continue
+			}
+			entries = append(entries, entry)
+		}
+
+	case ".txt", "":
+		for _, line := range strings.Split(string(data), "\n") {
+			line = strings.TrimSpace(line)
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			entries = append(entries, importEntry{Word: line})
+		}
+
+	default:
+		return nil, nil, fmt.Errorf("unsupported file type %q, use .txt, .csv or .json", path.Ext(filename))
+	}
+
+	valid := entries[:0]
+	seen := make(map[string]bool)
+	for _, entry := range entries {
+		if err := entry.normalize(); err != nil {
+			problems = append(problems, err.Error())
+			continue
+		}
+		if seen[entry.Word] {
+			problems = append(problems, fmt.Sprintf("duplicate word %q", entry.Word))
+			continue
+		}
+		seen[entry.Word] = true
+		valid = append(valid, entry)
+	}
+	return valid, problems, nil
+}
+
+func downloadAttachment(s *discordgo.Session, attachment *discordgo.MessageAttachment) ([]byte, error) {
+	if attachment.Size > maxImportSize {
+		return nil, fmt.Errorf("file is too large (%d bytes, max %d)", attachment.Size, maxImportSize)
+	}
+
+	resp, err := s.Client.Get(attachment.URL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("downloading attachment: %s", resp.Status)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, maxImportSize))
+}
+
+func wordsImportHandler(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	response := "Skill issue"
+	adminUserID := os.Getenv("ADMIN_ID")
+
+	if i.Member.User.ID != adminUserID {
+		if err := sendResponse(s, i, response); err != nil {
+			log.Printf("Error sending detailed response: %v", err)
+		}
+		return
+	}
+
+	data := i.ApplicationCommandData()
+	var attachment *discordgo.MessageAttachment
+	commit := false
+	for _, option := range data.Options[0].Options {
+		switch option.Name {
+		case "file":
+			attachment = data.Resolved.Attachments[option.Value.(string)]
+		case "commit":
+			commit = option.BoolValue()
+		}
+	}
+	if attachment == nil {
+		if err := sendResponse(s, i, "No file attached."); err != nil {
+			log.Printf("Error sending detailed response: %v", err)
+		}
+		return
+	}
+
+	content, err := downloadAttachment(s, attachment)
+	if err != nil {
+		response = fmt.Sprintf("Failed to read file: %v", err)
+		if err := sendResponse(s, i, response); err != nil {
+			log.Printf("Error sending detailed response: %v", err)
+		}
+		return
+	}
+
+	entries, problems, err := parseWordList(attachment.Filename, content)
+	if err != nil {
+		response = fmt.Sprintf("Failed to parse file: %v", err)
+		if err := sendResponse(s, i, response); err != nil {
+			log.Printf("Error sending detailed response: %v", err)
+		}
+		return
+	}
+
+	existing := make(map[string]flaggedWord)
+	if matcher := currentMatcher.Load(); matcher != nil {
+		for _, w := range matcher.words {
+			existing[w.Word] = w
+		}
+	}
+
+	var added, updated []string
+	unchanged := 0
+	for _, entry := range entries {
+		old, ok := existing[entry.Word]
+		entry = entry.over(old, ok)
+		switch {
+		case !ok:
+			added = append(added, entry.Word)
+		case old.Mode != entry.Mode || old.Weight != entry.Weight || old.Category != entry.Category:
+			updated = append(updated, fmt.Sprintf("%s (%s, %d, %s -> %s, %d, %s)",
+				entry.Word, old.Mode, old.Weight, old.Category, entry.Mode, entry.Weight, entry.Category))
+		default:
+			unchanged++
+		}
+	}
+
+	var b strings.Builder
+	if commit {
+		b.WriteString("# Import\n")
+	} else {
+		b.WriteString("# Import preview (dry run)\n")
+	}
+	fmt.Fprintf(&b, "**%d** new, **%d** updated, **%d** unchanged, **%d** skipped.\n", len(added), len(updated), unchanged, len(problems))
+	writePreviewList(&b, "New", added)
+	writePreviewList(&b, "Updated", updated)
+	writePreviewList(&b, "Skipped", problems)
+
+	if !commit {
+		b.WriteString("\nNothing has been changed. Run the command again with `commit: True` to apply it.")
+		if err := sendResponse(s, i, b.String()); err != nil {
+			log.Printf("Error sending detailed response: %v", err)
+		}
+		return
+	}
+
+	if len(added) > 0 || len(updated) > 0 {
+		if err := importWords(entries); err != nil {
+			response = fmt.Sprintf("Failed to import words: %v", err)
+			if err := sendResponse(s, i, response); err != nil {
+				log.Printf("Error sending detailed response: %v", err)
+			}
+			return
+		}
+		loadWordMap()
+	}
+
+	b.WriteString("\nThe word list has been updated. Note: this will only affect new messages.")
+	if err := sendResponse(s, i, b.String()); err != nil {
+		log.Printf("Error sending detailed response: %v", err)
+	}
+}
+
+func writePreviewList(b *strings.Builder, title string, lines []string) {
+	if len(lines) == 0 {
+		return
+	}
+	fmt.Fprintf(b, "\n**%s:**\n", title)
+	for k, line := range lines {
+		if k == maxPreviewLines {
+			fmt.Fprintf(b, "...and %d more\n", len(lines)-maxPreviewLines)
+			break
+		}
+		fmt.Fprintf(b, "> %s\n", line)
+	}
+}
+
+// importWords upserts all entries in a single transaction so a failed import
+// leaves the word list untouched.
+func importWords(entries []importEntry) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	tx, err := dbpool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	// Columns the file left out are passed as NULL and keep the stored value,
+	// or get the default for a new word.
+	query := `INSERT INTO words (word, mode, weight, category)
+		VALUES ($1, COALESCE($2, 'contains'), COALESCE($3, 1), $4)
+		ON CONFLICT (word) DO UPDATE SET mode = COALESCE($2, words.mode), weight = COALESCE($3, words.weight), category = COALESCE($4, words.category);`
+	for _, entry := range entries {
+		var mode, category *string
+		var weight *int
+		if entry.Mode != "" {
+			mode = &entry.Mode
+		}
+		if entry.Weight != 0 {
+			weight = &entry.Weight
+		}
+		if entry.Category != "" {
+			category = &entry.Category
+		}
+		if _, err := tx.Exec(ctx, query, entry.Word, mode, weight, category); err != nil {
+			return fmt.Errorf("word %q: %v", entry.Word, err)
+		}
+	}
+	return tx.Commit(ctx)
+}
+
+func wordsExportHandler(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	format := "csv"
+	for _, option := range i.ApplicationCommandData().Options[0].Options {
+		if option.Name == "format" {
+			format = option.StringValue()
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	rows, err := dbpool.Query(ctx, `SELECT word, mode, weight, COALESCE(category, '') FROM words ORDER BY word;`)
+	if err != nil {
+		log.Printf("Error executing query: %v", err)
+		if err := sendResponse(s, i, "Error fetching words."); err != nil {
+			log.Printf("Error sending detailed response: %v", err)
+		}
+		return
+	}
+	defer rows.Close()
+
+	var entries []importEntry
+	for rows.Next() {
+		var entry importEntry
+		if err := rows.Scan(&entry.Word, &entry.Mode, &entry.Weight, &entry.Category); err != nil {
+			log.Printf("Error scanning row: %v", err)
+			continue
+		}
+		entries = append(entries, entry)
+	}
+
+	if err := rows.Err(); err != nil {
+		log.Printf("Error during row iteration: %v", err)
+		if err := sendResponse(s, i, "Error processing words."); err != nil {
+			log.Printf("Error sending detailed response: %v", err)
+		}
+		return
+	}
+
+	var buf bytes.Buffer
+	contentType := "text/plain"
+	switch format {
+	case "json":
+		contentType = "application/json"
+		encoder := json.NewEncoder(&buf)
+		encoder.SetIndent("", "  ")
+		if entries == nil {
+			entries = []importEntry{}
+		}
+		err = encoder.Encode(entries)
+	case "csv":
+		contentType = "text/csv"
+		writer := csv.NewWriter(&buf)
+		writer.Write([]string{"word", "mode", "weight", "category"})
+		for _, entry := range entries {
+			writer.Write([]string{entry.Word, entry.Mode, strconv.Itoa(entry.Weight), entry.Category})
+		}
+		writer.Flush()
+		err = writer.Error()
+	default:
+		for _, entry := range entries {
+			buf.WriteString(entry.Word + "\n")
+		}
+	}
+	if err != nil {
+		log.Printf("Error encoding word list: %v", err)
+		if err := sendResponse(s, i, "Error exporting words."); err != nil {
+			log.Printf("Error sending detailed response: %v", err)
+		}
+		return
+	}
+
+	file := &discordgo.File{
+		Name:        "words." + format,
+		ContentType: contentType,
+		Reader:      &buf,
+	}
+	response := fmt.Sprintf("Exported %d words.", len(entries))
+	if err := sendFileResponse(s, i, response, file); err != nil {
+		log.Printf("Error sending detailed response: %v", err)
+	}
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseWordListCSVLines(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want []string
+	}{
+		{"without header", "darn,contains,x\nheck,exact,2\n", []string{`line 1: invalid weight "x"`}},
+		{"with header", "word,mode,weight\ndarn,contains,1\nheck,exact,x\n", []string{`line 3: invalid weight "x"`}},
+		{"blank lines", "word,weight\n\ndarn,1\n\nheck,x\n", []string{`line 5: invalid weight "x"`}},
+	}
+	for _, tt := range tests {
+		_, problems, err := parseWordList("words.csv", []byte(tt.data))
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(problems, tt.want) {
+			t.Errorf("%s: problems = %q, want %q", tt.name, problems, tt.want)
+		}
+	}
+}
+
+func TestParseWordListCSVEntries(t *testing.T) {
+	data := "word,mode,weight,category\nDarn,exact,2,mild\nheck,,x,\nblast,,,\n"
+	entries, problems, err := parseWordList("words.csv", []byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The row with the bad weight is skipped entirely, and columns left empty
+	// stay empty so they do not overwrite stored values.
+	want := []importEntry{
+		{Word: "darn", Mode: matchExact, Weight: 2, Category: "mild"},
+		{Word: "blast"},
+	}
+	if !reflect.DeepEqual(entries, want) {
+		t.Errorf("entries = %+v, want %+v", entries, want)
+	}
+	if len(problems) != 1 {
+		t.Errorf("problems = %q, want one", problems)
+	}
+}
+
+func TestImportEntryOver(t *testing.T) {
+	old := flaggedWord{Word: "darn", Mode: matchExact, Weight: 3, Category: "mild"}
+	tests := []struct {
+		entry  importEntry
+		exists bool
+		want   importEntry
+	}{
+		{importEntry{Word: "darn"}, true, importEntry{Word: "darn", Mode: matchExact, Weight: 3, Category: "mild"}},
+		{importEntry{Word: "darn", Weight: 5}, true, importEntry{Word: "darn", Mode: matchExact, Weight: 5, Category: "mild"}},
+		{importEntry{Word: "darn"}, false, importEntry{Word: "darn", Mode: matchContains, Weight: 1}},
+	}
+	for _, tt := range tests {
+		if got := tt.entry.over(old, tt.exists); got != tt.want {
+			t.Errorf("%+v over %+v (exists %v) = %+v, want %+v", tt.entry, old, tt.exists, got, tt.want)
+		}
+	}
+}